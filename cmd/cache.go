@@ -0,0 +1,343 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neuralnorthwest/tpology/git"
+	"github.com/neuralnorthwest/tpology/table"
+	"github.com/spf13/cobra"
+)
+
+// CacheConfig is the configuration of the cache commands.
+type CacheConfig struct {
+	// Format is the output format of cache list.
+	Format string
+	// OlderThan is the age of the repositories removed by cache prune, e.g.
+	// 30d.
+	OlderThan string
+}
+
+// cacheCommand returns the cache command.
+func cacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the Git cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(cacheListCommand())
+	cmd.AddCommand(cachePruneCommand())
+	cmd.AddCommand(cacheVerifyCommand())
+	cmd.AddCommand(cacheGCCommand())
+	cmd.AddCommand(cacheRemoveCommand())
+	return cmd
+}
+
+// cacheListCommand returns the cache list command.
+func cacheListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the cached repositories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cacheList()
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	formatFlag(cmd, &config.Cache.Format, FormatTable, FormatJSON, FormatYAML)
+	return cmd
+}
+
+// cachePruneCommand returns the cache prune command.
+func cachePruneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the cached repositories not fetched recently and unused worktrees",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cachePrune()
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.Flags().StringVar(&config.Cache.OlderThan, "older-than", "30d", "remove repositories last fetched longer ago than this, e.g. 30d, 2w or 12h")
+	return cmd
+}
+
+// cacheVerifyCommand returns the cache verify command.
+func cacheVerifyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify [url...]",
+		Short: "Check the objects and remote URL of cached repositories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cacheVerify(args)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+}
+
+// cacheGCCommand returns the cache gc command.
+func cacheGCCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "gc [url...]",
+		Short: "Pack cached repositories and remove their unreachable objects",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cacheGC(args)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+}
+
+// cacheRemoveCommand returns the cache remove command.
+func cacheRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <url>...",
+		Aliases: []string{"rm"},
+		Short:   "Remove cached repositories",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cacheRemove(args)
+		},
+		Args:          cobra.MinimumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+}
+
+// cacheList lists the cached repositories. Repositories that cannot be read
+// are listed with what is known and reported on stderr.
+func cacheList() error {
+	cache, err := gitCache()
+	if err != nil {
+		return err
+	}
+	repos, err := cache.Repositories()
+	if err != nil {
+		return err
+	}
+	infos := []*git.RepositoryInfo{}
+	for _, r := range repos {
+		info, err := r.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		infos = append(infos, info)
+	}
+	switch Format(config.Cache.Format) {
+	case FormatTable:
+		return printCacheTable(infos)
+	case FormatJSON:
+		return printJSON(infos)
+	case FormatYAML:
+		return printYAML(infos)
+	default:
+		return fmt.Errorf("unknown format: %s", config.Cache.Format)
+	}
+}
+
+// printCacheTable prints the cached repositories as a table.
+func printCacheTable(infos []*git.RepositoryInfo) error {
+	if len(infos) == 0 {
+		return nil
+	}
+	t := table.New()
+	for _, h := range []string{"URL", "Ref", "Head", "Size", "Last Fetch"} {
+		t.InsertColumn(h, table.AtEnd)
+	}
+	for _, info := range infos {
+		head := info.Head
+		if len(head) > 12 {
+			head = head[:12]
+		}
+		lastFetch := ""
+		if !info.LastFetch.IsZero() {
+			lastFetch = info.LastFetch.Format(time.RFC3339)
+		}
+		url := info.URL
+		if info.Mirror {
+			url += " (mirror)"
+		}
+		if err := t.InsertRow([]interface{}{url, info.Ref, head, formatSize(info.Size), lastFetch}, table.AtEnd); err != nil {
+			return err
+		}
+	}
+	return t.Write(os.Stdout, table.MarkdownFormatter())
+}
+
+// cachePrune removes the cached repositories not fetched recently, and the
+// worktrees no longer in use.
+func cachePrune() error {
+	age, err := parseAge(config.Cache.OlderThan)
+	if err != nil {
+		return err
+	}
+	cache, err := gitCache()
+	if err != nil {
+		return err
+	}
+	removed, err := cache.Prune(age)
+	if !config.Global.Quiet {
+		for _, r := range removed {
+			fmt.Printf("removed %s\n", cachedName(r))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	worktrees, err := cache.PruneWorktrees()
+	if !config.Global.Quiet {
+		for _, dir := range worktrees {
+			fmt.Printf("removed worktree %s\n", dir)
+		}
+	}
+	return err
+}
+
+// cacheVerify verifies the cached repositories of the URLs, or all of them.
+// Every repository is verified, and the failures are reported together.
+func cacheVerify(urls []string) error {
+	cache, repos, err := cachedRepositories(urls)
+	if err != nil {
+		return err
+	}
+	return eachCached(repos, "verified", cache.Verify)
+}
+
+// cacheGC garbage collects the cached repositories of the URLs, or all of
+// them.
+func cacheGC(urls []string) error {
+	_, repos, err := cachedRepositories(urls)
+	if err != nil {
+		return err
+	}
+	return eachCached(repos, "packed", (*git.Repository).GC)
+}
+
+// cacheRemove removes the cached repositories of the URLs.
+func cacheRemove(urls []string) error {
+	cache, err := gitCache()
+	if err != nil {
+		return err
+	}
+	for _, u := range urls {
+		if err := cache.Remove(u); err != nil {
+			return err
+		}
+		if !config.Global.Quiet {
+			fmt.Printf("removed %s\n", u)
+		}
+	}
+	return nil
+}
+
+// cachedRepositories returns the Git cache and the cached repositories and
+// mirrors of the URLs, or all of them if there are none.
+func cachedRepositories(urls []string) (*git.Cache, []*git.Repository, error) {
+	cache, err := gitCache()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(urls) == 0 {
+		repos, err := cache.Repositories()
+		return cache, repos, err
+	}
+	repos := []*git.Repository{}
+	for _, u := range urls {
+		n := len(repos)
+		for _, r := range []*git.Repository{cache.New(u, ""), cache.Mirror(u)} {
+			if r.IsCloned() {
+				repos = append(repos, r)
+			}
+		}
+		if len(repos) == n {
+			return nil, nil, fmt.Errorf("%w: %s", git.ErrorNotCached, u)
+		}
+	}
+	return cache, repos, nil
+}
+
+// eachCached applies an operation to repositories, reporting each outcome.
+// It carries on after failures and returns an error if any failed.
+func eachCached(repos []*git.Repository, done string, op func(*git.Repository) error) error {
+	failed := 0
+	for _, r := range repos {
+		if err := op(r); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cachedName(r), err)
+			failed++
+			continue
+		}
+		if !config.Global.Quiet {
+			fmt.Printf("%s %s\n", done, cachedName(r))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cached repositories failed", failed, len(repos))
+	}
+	return nil
+}
+
+// cachedName returns the name of a cached repository in messages: its URL,
+// or its directory if the URL is unknown. Mirrors are marked as such.
+func cachedName(r *git.Repository) string {
+	name := r.Dir
+	if r.URL != "" {
+		name = git.RedactURL(r.URL)
+	}
+	if r.Bare {
+		name += " (mirror)"
+	}
+	return name
+}
+
+// parseAge parses a duration, also accepting a number of days (d) or weeks
+// (w), e.g. 30d.
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q, expected e.g. 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. 1.5 MiB.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
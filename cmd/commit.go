@@ -0,0 +1,189 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/neuralnorthwest/tpology/git"
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/spf13/cobra"
+)
+
+// CommitConfig is the configuration of the commands changing the inventory.
+type CommitConfig struct {
+	// Commit commits the change on a topic branch and pushes it.
+	Commit bool
+	// Message is the commit message.
+	Message string
+	// Branch is the name of the topic branch.
+	Branch string
+	// Author is the author of the commit, as "Name <email>".
+	Author string
+}
+
+// SetupFlags sets up the commit flags of a command changing the inventory.
+func (c *CommitConfig) SetupFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&c.Commit, "commit", false, "commit the change on a topic branch and push it for review")
+	cmd.Flags().StringVarP(&c.Message, "message", "m", "", "commit message (default \"<Verb> <kind>/<name>\")")
+	cmd.Flags().StringVar(&c.Branch, "branch", "", "topic branch name (default itool/<verb>-<kind>-<name>-<timestamp>)")
+	cmd.Flags().StringVar(&c.Author, "author", "", "commit author as \"Name <email>\" (default the configured Git user)")
+}
+
+// changeInventory runs write, which changes a manifest of the inventory at
+// invPath and returns its path, or "" if nothing changed. With --commit, the
+// change is made on a new topic branch, committed and pushed to the origin
+// remote, and the branch checked out before is restored. A local inventory
+// is rebased onto its upstream first, unless offline. The worktree of the
+// cached inventory is shared with other processes, so it is never changed:
+// with --commit (see checkInventoryWritable), the change is made in a
+// worktree of its own, while holding the exclusive lock of the mirror.
+func changeInventory(verb string, ref resource.Ref, write func(invPath string) (string, error)) error {
+	invPath, err := inventoryDir()
+	if err != nil {
+		return err
+	}
+	c := &config.Commit
+	if !c.Commit {
+		_, err := write(invPath)
+		return err
+	}
+	backend, err := git.ParseBackend(config.Global.GitBackend)
+	if err != nil {
+		return err
+	}
+	creds, err := gitCredentials()
+	if err != nil {
+		return err
+	}
+	if inventoryWorktree != nil {
+		dir, remove, err := changeWorktree(inventoryWorktree, backend)
+		if err != nil {
+			return err
+		}
+		defer remove()
+		invPath = dir
+	}
+	repo := git.Open(invPath)
+	repo.Backend = backend
+	repo.Credentials = creds
+	base, err := repo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("inventory is not a Git repository: %s: %w", invPath, err)
+	}
+	if config.Global.InventoryLocal != "" && !config.Global.Offline {
+		if err := repo.WithContext(commandContext).PullRebase(); err != nil {
+			return err
+		}
+	}
+	branch := c.Branch
+	if branch == "" {
+		branch = fmt.Sprintf("itool/%s-%s-%s-%s", verb, ref.Kind, ref.Name, time.Now().UTC().Format("20060102150405"))
+	}
+	if err := repo.CreateBranch(branch); err != nil {
+		return err
+	}
+	restore := func(err error, keepBranch bool) error {
+		if cerr := repo.Checkout(base); cerr != nil {
+			return fmt.Errorf("%v (checking out %s: %v)", err, base, cerr)
+		}
+		if !keepBranch {
+			if derr := repo.DeleteBranch(branch); derr != nil {
+				return fmt.Errorf("%v (%v)", err, derr)
+			}
+		}
+		return err
+	}
+	path, err := write(invPath)
+	if err != nil || path == "" {
+		return restore(err, false)
+	}
+	rel, err := filepath.Rel(invPath, path)
+	if err != nil {
+		return restore(err, false)
+	}
+	if err := repo.Add(rel); err != nil {
+		return restore(err, false)
+	}
+	message := c.Message
+	if message == "" {
+		message = fmt.Sprintf("%s %s", strings.ToUpper(verb[:1])+verb[1:], ref)
+	}
+	if err := repo.Commit(c.Author, message); err != nil {
+		if errors.Is(err, git.ErrorNothingToCommit) {
+			return restore(nil, false)
+		}
+		return restore(err, false)
+	}
+	if err := repo.WithContext(commandContext).Push(branch); err != nil {
+		return restore(fmt.Errorf("committed to branch %s but not pushed: %w", branch, err), true)
+	}
+	if !config.Global.Quiet {
+		fmt.Printf("committed to branch %s and pushed to origin\n", branch)
+	}
+	// The branches of the mirror are of no use once pushed.
+	return restore(nil, inventoryWorktree == nil)
+}
+
+// changeWorktree adds a temporary worktree of the commit of a worktree of the
+// cached inventory, to change it on a topic branch. The exclusive lock of the
+// mirror is held until the returned function removes the worktree, so that
+// changes and fetches do not run meanwhile.
+func changeWorktree(w *git.Worktree, backend git.Backend) (string, func(), error) {
+	if _, ok := backend.(git.GoBackend); ok {
+		// Its worktrees are copies of the commit, without a Git directory.
+		return "", nil, fmt.Errorf("%w: committing to the cached inventory with the go backend, use --git-backend exec or --inventory-local", git.ErrorUnsupported)
+	}
+	unlock, err := lockInventory(w.Mirror, git.LockExclusive)
+	if err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.MkdirTemp("", "itool-change-")
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+	dir := filepath.Join(tmp, "inventory")
+	mirror := *w.Mirror
+	mirror.CloneOptions.SparsePaths = w.CloneOptions.SparsePaths
+	if err := mirror.WithContext(commandContext).AddWorktree(dir, w.Commit); err != nil {
+		_ = mirror.RemoveWorktree(dir)
+		os.RemoveAll(tmp)
+		unlock()
+		return "", nil, err
+	}
+	return dir, func() {
+		if err := mirror.RemoveWorktree(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		os.RemoveAll(tmp)
+		unlock()
+	}, nil
+}
+
+// checkInventoryWritable returns an error if the inventory is the cached
+// one and the change is not committed, which would leave the change in the
+// cache for every other command to see.
+func checkInventoryWritable() error {
+	if config.Global.InventoryLocal == "" && !config.Commit.Commit {
+		return errors.New("the cached inventory cannot be changed in place: use --commit to push the change for review, or --inventory-local to change a local checkout")
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -31,8 +32,45 @@ type GlobalConfig struct {
 	InventoryLocal string
 	// Inventory is the URL to the inventory repository
 	Inventory string
-	// InventoryRef is the Git reference to the inventory repository
+	// InventoryRef is the branch, tag or commit of the inventory repository
 	InventoryRef string
+	// InventoryDepth is the number of commits of a shallow clone of the
+	// inventory repository, or zero for the whole history.
+	InventoryDepth int
+	// InventoryPaths are the directories of the inventory repository to check
+	// out, or empty for all of them.
+	InventoryPaths []string
+	// Offline skips fetching the inventory repository and uses the cached copy.
+	Offline bool
+	// Merge is the policy for resources defined more than once.
+	Merge string
+	// KeepGoing uses the partially loaded inventory when it has errors.
+	KeepGoing bool
+	// GitBackend is the Git backend: auto, exec or go.
+	GitBackend string
+	// LockTimeout is how long to wait for other processes to release the
+	// inventory repository.
+	LockTimeout time.Duration
+	// GitUsername is the user name sent with the Git token.
+	GitUsername string
+	// GitTokenEnv is the environment variable holding the Git token.
+	GitTokenEnv string
+	// GitTokenFile is the file holding the Git token, read instead of
+	// GitTokenEnv.
+	GitTokenFile string
+	// GitSSHKey is the path to the private key of SSH Git remotes.
+	GitSSHKey string
+	// GitCredentialHelper is the git credential helper used instead of the
+	// configured ones.
+	GitCredentialHelper string
+	// Profile is the profile of the configuration files to use.
+	Profile string
+	// Timeout is how long the Git operations of a command may take, or zero
+	// for no limit.
+	Timeout time.Duration
+	// At is the ref or date the commands reading the inventory load it as
+	// of, or empty for the checked out inventory.
+	At string
 }
 
 // Config holds all configuration.
@@ -41,6 +79,14 @@ type Config struct {
 	Global GlobalConfig
 	// Resource is the resource configuration.
 	Resource ResourceConfig
+	// Graph is the graph configuration.
+	Graph GraphConfig
+	// Commit is the configuration of the commands changing the inventory.
+	Commit CommitConfig
+	// Cache is the configuration of the cache commands.
+	Cache CacheConfig
+	// ConfigView is the configuration of config view.
+	ConfigView ConfigViewConfig
 }
 
 // config is the global configuration.
@@ -49,10 +95,29 @@ var config = &Config{}
 // SetupFlags sets up the flags for the itool command.
 func (c *GlobalConfig) SetupFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVarP(&c.Quiet, "quiet", "q", false, "suppress all output")
+	cmd.PersistentFlags().StringVar(&c.Profile, "profile", "", "profile of the configuration files to use (default from ITOOL_PROFILE or the files, else \"default\")")
 	cmd.PersistentFlags().StringVar(&c.GitCacheDir, "git-cache-dir", gitCacheDir(), "path to the Git cache directory")
 	cmd.PersistentFlags().StringVarP(&c.InventoryLocal, "inventory-local", "l", "", "path to the local inventory repository")
-	cmd.PersistentFlags().StringVarP(&c.Inventory, "inventory", "i", "https://github.com/ZeroEyesTech/ZE-Inventory.git", "URL to the inventory repository")
-	cmd.PersistentFlags().StringVarP(&c.InventoryRef, "inventory-ref", "r", "main", "Git reference to the inventory repository")
+	cmd.PersistentFlags().StringVarP(&c.Inventory, "inventory", "i", "", "URL to the inventory repository")
+	cmd.PersistentFlags().StringVarP(&c.InventoryRef, "inventory-ref", "r", "main", "branch, tag or commit of the inventory repository")
+	cmd.PersistentFlags().IntVar(&c.InventoryDepth, "inventory-depth", 0, "clone only this many commits of the inventory repository (0 for the whole history)")
+	cmd.PersistentFlags().StringSliceVar(&c.InventoryPaths, "inventory-paths", nil, "clone only these directories of the inventory repository, e.g. hosts,networks")
+	cmd.PersistentFlags().BoolVar(&c.Offline, "offline", false, "use the cached inventory repository without fetching")
+	cmd.PersistentFlags().StringVar(&c.Merge, "merge", "none", "policy for resources defined more than once (none, replace or deep)")
+	cmd.PersistentFlags().BoolVar(&c.KeepGoing, "keep-going", false, "report inventory errors and carry on with the resources that loaded")
+	cmd.PersistentFlags().StringVar(&c.GitBackend, "git-backend", "auto", "Git backend: exec runs the git binary, go runs in process, auto uses git if installed")
+	cmd.PersistentFlags().DurationVar(&c.LockTimeout, "lock-timeout", 30*time.Second, "how long to wait for other itool processes to release the inventory repository")
+	cmd.PersistentFlags().StringVar(&c.GitUsername, "git-username", "", "user name sent with the Git token (default \"git\")")
+	cmd.PersistentFlags().StringVar(&c.GitTokenEnv, "git-token-env", "ITOOL_GIT_TOKEN", "environment variable holding the token of HTTPS Git remotes")
+	cmd.PersistentFlags().StringVar(&c.GitTokenFile, "git-token-file", "", "file holding the token of HTTPS Git remotes, instead of --git-token-env")
+	cmd.PersistentFlags().StringVar(&c.GitSSHKey, "git-ssh-key", "", "path to the private key of SSH Git remotes")
+	cmd.PersistentFlags().StringVar(&c.GitCredentialHelper, "git-credential-helper", "", "git credential helper to use instead of the configured ones, e.g. store")
+	cmd.PersistentFlags().DurationVar(&c.Timeout, "timeout", 0, "abort the command if it takes longer than this, e.g. 2m (0 for no limit)")
+}
+
+// setupAtFlag sets up the --at flag of a command reading the inventory.
+func setupAtFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config.Global.At, "at", "", "read the inventory as of a commit, tag, branch or date, e.g. v1.4 or 2023-06-01 (end of the day)")
 }
 
 // gitCacheDir returns the path to the user's git cache directory.
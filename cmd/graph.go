@@ -0,0 +1,86 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/neuralnorthwest/tpology/inventory"
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/spf13/cobra"
+)
+
+// GraphConfig is the graph configuration.
+type GraphConfig struct {
+	// Format is the output format.
+	Format string
+	// Kinds are the kinds of the resources to include.
+	Kinds []string
+	// Owners are the owners of the resources to include.
+	Owners []string
+	// Root is the resource whose dependencies are included.
+	Root string
+	// MaxDepth is the maximum distance from Root of the included resources.
+	MaxDepth int
+}
+
+// SetupFlags sets up the flags for the graph command.
+func (c *GraphConfig) SetupFlags(cmd *cobra.Command) {
+	formatFlag(cmd, &c.Format, FormatDOT, FormatMermaid, FormatJSON, FormatYAML, FormatTable)
+	cmd.Flags().StringSliceVarP(&c.Kinds, "kind", "k", nil, "include only resources of these kinds")
+	cmd.Flags().StringSliceVarP(&c.Owners, "owner", "o", nil, "include only resources with these owners")
+	cmd.Flags().StringVar(&c.Root, "root", "", "include only the dependencies of this <kind>/<name> resource")
+	cmd.Flags().IntVar(&c.MaxDepth, "max-depth", 0, "maximum distance from the root resource (0 for no limit)")
+}
+
+// graphCommand returns the graph command.
+func graphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the resource dependency graph",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return graph()
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	config.Graph.SetupFlags(cmd)
+	setupAtFlag(cmd)
+	return cmd
+}
+
+// graph exports the resource dependency graph.
+func graph() error {
+	inv, rev, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	filter := inventory.SubgraphFilter{
+		Kinds:    config.Graph.Kinds,
+		Owners:   config.Graph.Owners,
+		MaxDepth: config.Graph.MaxDepth,
+	}
+	if config.Graph.Root != "" {
+		root, err := resource.ParseRef(config.Graph.Root)
+		if err != nil {
+			return err
+		}
+		filter.Root = &root
+	}
+	sub, err := inv.Subgraph(filter)
+	if err != nil {
+		return err
+	}
+	return printGraph(sub, Format(config.Graph.Format), rev)
+}
@@ -15,17 +15,467 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/neuralnorthwest/tpology/git"
 	"github.com/neuralnorthwest/tpology/inventory"
+	"github.com/neuralnorthwest/tpology/resource"
 )
 
-// loadInventory loads the inventory.
-func loadInventory() (*inventory.Inventory, error) {
-	invPath := config.Global.InventoryLocal
-	if invPath == "" {
-		cache := git.NewCache(config.Global.GitCacheDir)
-		invRepo := cache.New(config.Global.Inventory, config.Global.InventoryRef)
-		invPath = invRepo.Dir
+// Revision identifies the commit of the inventory that an output was
+// generated from.
+type Revision struct {
+	// URL is the URL of the inventory repository, without password, or
+	// empty for a local inventory.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Path is the path to the local inventory, or empty for the inventory
+	// repository.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Ref is the configured Git reference, or the checked out branch of a
+	// local inventory.
+	Ref string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	// Commit is the hash of the checked out commit, or empty if the inventory
+	// is not in a Git repository.
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	// Dirty is true if the checkout of the inventory has uncommitted changes.
+	Dirty bool `json:"dirty,omitempty" yaml:"dirty,omitempty"`
+}
+
+// String returns the revision as "<url or path> <ref> <commit>".
+func (v *Revision) String() string {
+	parts := []string{v.URL}
+	if v.URL == "" {
+		parts = []string{v.Path}
+	}
+	if v.Ref != "" && v.Ref != v.Commit {
+		parts = append(parts, v.Ref)
+	}
+	if v.Commit != "" {
+		parts = append(parts, v.Commit)
+	}
+	if v.Dirty {
+		parts = append(parts, "(dirty)")
+	}
+	return strings.Join(parts, " ")
+}
+
+// loadInventory loads the inventory, and returns it along with the revision
+// it was loaded from. With --at, the inventory is loaded as of a past
+// revision.
+func loadInventory() (*inventory.Inventory, *Revision, error) {
+	invPath, err := inventoryDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	repo := inventoryRepo(invPath)
+	if config.Global.At != "" {
+		return loadInventoryAt(repo, config.Global.At)
+	}
+	// The worktree of a cached inventory is checked out at a commit, and
+	// referenced until the command is done, so it does not change meanwhile.
+	inv, err := loadInventoryFrom(invPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, inventoryRevision(repo), nil
+}
+
+// loadInventoryAt loads the inventory as of a ref or date. A cached
+// inventory is loaded from the worktree of that commit, acquired from the
+// mirror, and a local one from a temporary worktree of its repository,
+// leaving the checkout as is.
+func loadInventoryAt(repo *git.Repository, at string) (*inventory.Inventory, *Revision, error) {
+	if inventoryWorktree != nil {
+		// Keep the mirror from being fetched while its refs are read.
+		unlock, err := lockInventory(inventoryWorktree.Mirror, git.LockShared)
+		if err != nil {
+			return nil, nil, err
+		}
+		mirror := inventoryWorktree.Mirror.WithContext(commandContext)
+		commit, err := resolveAt(mirror, inventoryWorktree.Commit, at)
+		unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		w, err := acquireWorktree(commit)
+		if err != nil {
+			return nil, nil, err
+		}
+		inv, err := loadInventoryFrom(w.Dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		rev := inventorySource(repo)
+		rev.Ref, rev.Commit = at, commit
+		return inv, rev, nil
+	}
+	commit, err := resolveAt(repo.WithContext(commandContext), "HEAD", at)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp, err := os.MkdirTemp("", "itool-at-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "inventory")
+	if err := repo.WithContext(commandContext).AddWorktree(dir, commit); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := repo.RemoveWorktree(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}()
+	inv, err := loadInventoryFrom(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	rev := inventorySource(repo)
+	rev.Ref, rev.Commit = at, commit
+	return inv, rev, nil
+}
+
+// atLayouts are the layouts of the dates accepted by --at. A date without a
+// time stands for the end of the day.
+var atLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// resolveAt returns the commit of the inventory repository at a ref, a
+// branch of the origin remote, or else a date of the history of base, in
+// local time unless it has a time zone. In the mirror of a cached
+// inventory, HEAD stands for base, and the branches of the origin remote
+// come first, its own branches being those of its clone.
+func resolveAt(repo *git.Repository, base, at string) (string, error) {
+	refs := []string{at, "origin/" + at}
+	if inventoryWorktree != nil {
+		refs[0], refs[1] = refs[1], refs[0]
+		if rest := strings.TrimPrefix(at, "HEAD"); rest != at && (rest == "" || strings.ContainsAny(rest[:1], "~^")) {
+			refs = []string{base + rest}
+		}
+	}
+	for _, ref := range refs {
+		commit, err := repo.ResolveRef(ref)
+		if !errors.Is(err, git.ErrorRefNotFound) {
+			return commit, err
+		}
+	}
+	for _, layout := range atLayouts {
+		t, perr := time.ParseInLocation(layout, at, time.Local)
+		if perr != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return repo.ResolveRefAt(base, t)
+	}
+	return "", fmt.Errorf("%w: %s is neither a ref nor a date such as 2023-06-01", git.ErrorRefNotFound, at)
+}
+
+// inventoryRepo returns the Git repository of the inventory at a path, using
+// the configured backend.
+func inventoryRepo(invPath string) *git.Repository {
+	repo := git.Open(invPath)
+	if backend, err := git.ParseBackend(config.Global.GitBackend); err == nil {
+		repo.Backend = backend
+	}
+	// Partial clones fetch file contents on demand.
+	if creds, err := gitCredentials(); err == nil {
+		repo.Credentials = creds
+	}
+	return repo
+}
+
+// inventorySource returns the revision of the inventory with only its URL,
+// or its path if it is local.
+func inventorySource(repo *git.Repository) *Revision {
+	if config.Global.InventoryLocal != "" {
+		return &Revision{Path: repo.Dir}
+	}
+	return &Revision{URL: git.RedactURL(config.Global.Inventory)}
+}
+
+// inventoryRevision returns the revision of the checked out inventory. The
+// commit is left empty if it cannot be read.
+func inventoryRevision(repo *git.Repository) *Revision {
+	rev := inventorySource(repo)
+	if config.Global.InventoryLocal != "" {
+		rev.Ref, _ = repo.CurrentBranch()
+	} else {
+		rev.Ref = config.Global.InventoryRef
+	}
+	if commit, err := repo.HEAD(); err == nil {
+		rev.Commit = commit
+		rev.Dirty = !repo.IsClean()
+	} else if inventoryWorktree != nil {
+		// The worktrees of the go backend are copies of the commit, without
+		// a Git directory.
+		rev.Commit = inventoryWorktree.Commit
+	}
+	return rev
+}
+
+// inventoryWorktree is the worktree of the configured ref of the cached
+// inventory, once acquired by inventoryDir.
+var inventoryWorktree *git.Worktree
+
+// acquiredWorktrees are the worktrees of the cached inventory referenced by
+// the command. Main releases them once the command is done.
+var acquiredWorktrees []*git.Worktree
+
+// inventoryDir returns the path to the inventory. Unless a local inventory is
+// configured, the mirror of the inventory repository is fetched into the Git
+// cache first, and the path is that of the worktree of the configured ref.
+func inventoryDir() (string, error) {
+	if config.Global.InventoryLocal != "" {
+		return config.Global.InventoryLocal, nil
+	}
+	if config.Global.Inventory == "" {
+		return "", errNoInventory
+	}
+	if inventoryWorktree == nil {
+		if err := fetchInventory(); err != nil {
+			return "", err
+		}
+		w, err := acquireWorktree(config.Global.InventoryRef)
+		if err != nil {
+			return "", err
+		}
+		inventoryWorktree = w
+	}
+	return inventoryWorktree.Dir, nil
+}
+
+// acquireWorktree acquires the worktree of a ref of the cached inventory,
+// referenced until releaseInventory.
+func acquireWorktree(ref string) (*git.Worktree, error) {
+	cache, err := inventoryCache()
+	if err != nil {
+		return nil, err
+	}
+	// Adding the worktree is a checkout, only stopped by --timeout.
+	w, err := cache.Acquire(commandContext, config.Global.Inventory, ref)
+	if err != nil {
+		return nil, err
+	}
+	acquiredWorktrees = append(acquiredWorktrees, w)
+	return w, nil
+}
+
+// releaseInventory releases the worktrees of the cached inventory acquired
+// by the command. They are left in the cache until pruned.
+func releaseInventory() {
+	for _, w := range acquiredWorktrees {
+		w.Release()
+	}
+	acquiredWorktrees, inventoryWorktree = nil, nil
+}
+
+// inventoryCache returns the Git cache with the clone options of the
+// inventory repository.
+func inventoryCache() (*git.Cache, error) {
+	cache, err := gitCache()
+	if err != nil {
+		return nil, err
+	}
+	cache.CloneOptions = inventoryCloneOptions()
+	return cache, nil
+}
+
+// inventoryCloneOptions returns the options of the clone of the inventory
+// repository. A sparse clone also checks out the schemas and column sets,
+// and fetches file contents on demand.
+func inventoryCloneOptions() git.CloneOptions {
+	opts := git.CloneOptions{Depth: config.Global.InventoryDepth}
+	if len(config.Global.InventoryPaths) > 0 {
+		opts.SparsePaths = append([]string{inventory.SchemaDir, inventory.ColumnDir}, config.Global.InventoryPaths...)
+		opts.Filter = "blob:none"
+	}
+	return opts
+}
+
+// gitCache returns the Git cache, using the configured backend.
+func gitCache() (*git.Cache, error) {
+	backend, err := git.ParseBackend(config.Global.GitBackend)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := gitCredentials()
+	if err != nil {
+		return nil, err
+	}
+	cache := git.NewCache(config.Global.GitCacheDir)
+	cache.Backend = backend
+	cache.Credentials = creds
+	return cache, nil
+}
+
+// gitCredentials returns the configured credentials of the Git remotes, or
+// nil if there are none. The token is read from --git-token-file, or else
+// from the environment variable named by --git-token-env. Its value is never
+// part of the errors.
+func gitCredentials() (*git.Credentials, error) {
+	c := &config.Global
+	creds := &git.Credentials{Username: c.GitUsername, SSHKey: c.GitSSHKey, Helper: c.GitCredentialHelper}
+	if c.GitTokenFile != "" {
+		data, err := os.ReadFile(c.GitTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading git token: %w", err)
+		}
+		creds.Token = strings.TrimSpace(string(data))
+		if creds.Token == "" {
+			return nil, fmt.Errorf("git token file is empty: %s", c.GitTokenFile)
+		}
+	} else if c.GitTokenEnv != "" {
+		creds.Token = os.Getenv(c.GitTokenEnv)
+	}
+	if creds.Token == "" && creds.SSHKey == "" && creds.Helper == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// errNoInventory is the error of the commands reading the inventory when
+// none is configured.
+var errNoInventory = errors.New("no inventory configured (set --inventory, ITOOL_INVENTORY or a profile, or --inventory-local)")
+
+// errInventoryPartial is the error of a command run with --keep-going on an
+// inventory with errors, once its output is written.
+var errInventoryPartial = errors.New("inventory has errors, see above; the output only covers the resources that loaded")
+
+// inventoryPartial is true once the inventory was loaded with errors under
+// --keep-going. The errors are reported on stderr as they are found, and
+// Main then fails with errInventoryPartial.
+var inventoryPartial bool
+
+// loadInventoryFrom loads the inventory at a path. With --keep-going, the
+// errors are reported on stderr and the resources that loaded are returned.
+func loadInventoryFrom(invPath string) (*inventory.Inventory, error) {
+	policy, err := inventory.ParseMergePolicy(config.Global.Merge)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Global.KeepGoing {
+		return inventory.Load(invPath, inventory.WithMergePolicy(policy))
+	}
+	inv, err := inventory.Load(invPath, inventory.WithMergePolicy(policy), inventory.WithKeepGoing())
+	var errs inventory.Errors
+	if errors.As(err, &errs) {
+		fmt.Fprintln(os.Stderr, groupedErrors{errs})
+		inventoryPartial = true
+		return inv, nil
+	}
+	return inv, err
+}
+
+// writeManifest writes a manifest of the inventory at invPath, removing the
+// file if the manifest is empty, and checks that the inventory still loads.
+// If it does not, the file is restored and the errors are returned.
+func writeManifest(invPath, path string, m *resource.Manifest) error {
+	original, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if m.IsEmpty() {
+		err = os.Remove(path)
+	} else {
+		err = m.WriteFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	policy, err := inventory.ParseMergePolicy(config.Global.Merge)
+	if err != nil {
+		return err
+	}
+	if _, err := inventory.Load(invPath, inventory.WithMergePolicy(policy)); err != nil {
+		if existed {
+			if rerr := resource.ParseManifest(original).WriteFile(path); rerr != nil {
+				return fmt.Errorf("%v (restoring %s: %v)", err, path, rerr)
+			}
+		} else if rerr := os.Remove(path); rerr != nil {
+			return fmt.Errorf("%v (removing %s: %v)", err, path, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// fetchInventory clones the mirror of the inventory repository on first
+// use, waiting for other processes cloning it, and otherwise fetches it
+// under its exclusive lock. The worktrees of the commands reading the
+// inventory are left as is, so fetching does not wait for them. In offline
+// mode, the cached mirror is used as-is.
+func fetchInventory() error {
+	cache, err := inventoryCache()
+	if err != nil {
+		return err
+	}
+	mirror := cache.Mirror(config.Global.Inventory)
+	ctx, cancel := context.WithTimeout(commandContext, config.Global.LockTimeout)
+	defer cancel()
+	for !mirror.IsCloned() {
+		if config.Global.Offline {
+			return fmt.Errorf("inventory repository is not cached and offline mode is enabled: %s", git.RedactURL(mirror.URL))
+		}
+		// A fresh clone has the latest commits already.
+		err := mirror.CloneContext(commandContext)
+		if !errors.Is(err, git.ErrorLocked) {
+			return err
+		}
+		// Another process is cloning it: wait for it to finish, and clone
+		// again if it failed.
+		unlock, err := mirror.LockWithTimeout(ctx, git.LockShared)
+		if err != nil {
+			return err
+		}
+		unlock()
+	}
+	if config.Global.Offline {
+		return nil
+	}
+	unlock, err := mirror.LockWithTimeout(ctx, git.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return mirror.WithContext(commandContext).Fetch()
+}
+
+// lockInventory locks a repository of the inventory, waiting for other
+// processes up to --lock-timeout. It returns an unlock function.
+func lockInventory(repo *git.Repository, mode git.LockMode) (func(), error) {
+	ctx, cancel := context.WithTimeout(commandContext, config.Global.LockTimeout)
+	defer cancel()
+	return repo.LockWithTimeout(ctx, mode)
+}
+
+// runEditor opens a file in the editor named by $VISUAL or $EDITOR, or vi,
+// and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", editor, err)
 	}
-	return inventory.Load(invPath)
+	return nil
 }
@@ -14,11 +14,52 @@
 
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
 
-// Main is the entry point for the itool command.
+	"github.com/neuralnorthwest/tpology/inventory"
+	"github.com/spf13/cobra"
+)
+
+// commandContext is the context of the running command. It is done when
+// the command is interrupted or its --timeout elapses, which stops the Git
+// operations in progress.
+var commandContext = context.Background()
+
+// Main is the entry point for the itool command. Inventory errors are
+// returned grouped by file. Commands run with --keep-going on an inventory
+// with errors fail once they are done. The worktrees of the cached inventory
+// are referenced until then.
 func Main() error {
-	return rootCommand().Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cancel := func() {}
+	defer func() { cancel() }()
+	defer releaseInventory()
+	cmd := rootCommand()
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		commandContext = ctx
+		if config.Global.Timeout > 0 {
+			commandContext, cancel = context.WithTimeout(ctx, config.Global.Timeout)
+		}
+		return nil
+	}
+	err := cmd.Execute()
+	if err == nil && inventoryPartial {
+		return errInventoryPartial
+	}
+	var errs inventory.Errors
+	if errors.As(err, &errs) {
+		return groupedErrors{errs}
+	}
+	return err
 }
 
 func rootCommand() *cobra.Command {
@@ -32,6 +73,10 @@ func rootCommand() *cobra.Command {
 		SilenceErrors: true,
 	}
 	cmd.AddCommand(resourceCommand())
+	cmd.AddCommand(validateCommand())
+	cmd.AddCommand(graphCommand())
+	cmd.AddCommand(cacheCommand())
+	cmd.AddCommand(configCommand())
 	config.Global.SetupFlags(cmd)
 	return cmd
 }
@@ -17,37 +17,183 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/neuralnorthwest/tpology/inventory"
 	"github.com/neuralnorthwest/tpology/resource"
 	"github.com/neuralnorthwest/tpology/table"
+	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
 
 type Format string
 
 const (
-	FormatTable Format = "table"
-	FormatJSON  Format = "json"
-	FormatYAML  Format = "yaml"
+	FormatTable   Format = "table"
+	FormatJSON    Format = "json"
+	FormatYAML    Format = "yaml"
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
 )
 
-// printEntities prints entities in various formats.
-func printEntities(ents []interface{}, format Format) error {
+// formatsAnnotation is the annotation of a --format flag listing the formats
+// of its command. The format setting of a profile only applies to the
+// commands supporting it.
+const formatsAnnotation = "itool_formats"
+
+// formatFlag sets up the --format flag of a command supporting the formats,
+// the first of which is the default.
+func formatFlag(cmd *cobra.Command, p *string, formats ...Format) {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	list := names[0]
+	if len(names) > 1 {
+		list = strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+	cmd.Flags().StringVarP(p, "format", "f", names[0], "output format ("+list+")")
+	_ = cmd.Flags().SetAnnotation("format", formatsAnnotation, names)
+}
+
+// inventoryOutput is the JSON and YAML output of the commands reading the
+// inventory, along with the revision it was read from.
+type inventoryOutput struct {
+	// Inventory is the revision of the inventory.
+	Inventory *Revision `json:"inventory" yaml:"inventory"`
+	// Result is the output of the command.
+	Result interface{} `json:"result" yaml:"result"`
+}
+
+// printEntities prints entities in various formats, along with the revision
+// of the inventory.
+func printEntities(ents []interface{}, format Format, rev *Revision) error {
+	switch format {
+	case FormatTable:
+		if err := printTable(ents); err != nil {
+			return err
+		}
+		return printRevision(rev)
+	case FormatJSON:
+		return printJSON(inventoryOutput{rev, ents})
+	case FormatYAML:
+		return printYAML(inventoryOutput{rev, ents})
+	case FormatDOT, FormatMermaid:
+		return fmt.Errorf("format only supported for graphs: %s", format)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// printGraph prints a dependency graph in various formats, along with the
+// revision of the inventory. JSON and YAML print adjacency lists.
+func printGraph(g *inventory.Subgraph, format Format, rev *Revision) error {
 	switch format {
 	case FormatTable:
-		return printTable(ents)
+		if err := printGraphTable(g); err != nil {
+			return err
+		}
+		return printRevision(rev)
 	case FormatJSON:
-		return printJSON(ents)
+		return printJSON(inventoryOutput{rev, adjacency(g)})
 	case FormatYAML:
-		return printYAML(ents)
+		return printYAML(inventoryOutput{rev, adjacency(g)})
+	case FormatDOT:
+		return printDOT(os.Stdout, g, rev)
+	case FormatMermaid:
+		return printMermaid(os.Stdout, g, rev)
 	default:
 		return fmt.Errorf("unknown format: %s", format)
 	}
 }
 
+// adjacency returns the adjacency lists of a graph keyed by "<kind>/<name>".
+func adjacency(g *inventory.Subgraph) map[string][]string {
+	adj := make(map[string][]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		deps := []string{}
+		for _, dep := range g.Edges[n.Ref()] {
+			deps = append(deps, dep.String())
+		}
+		adj[n.Ref().String()] = deps
+	}
+	return adj
+}
+
+// printGraphTable prints a graph as a table of resources and their
+// dependencies.
+func printGraphTable(g *inventory.Subgraph) error {
+	t := table.New()
+	t.InsertColumn("Resource", table.AtEnd)
+	t.InsertColumn("Dependencies", table.AtEnd)
+	for _, n := range g.Nodes {
+		deps := []string{}
+		for _, dep := range g.Edges[n.Ref()] {
+			deps = append(deps, dep.String())
+		}
+		if err := t.InsertRow([]interface{}{n.Ref().String(), strings.Join(deps, ", ")}, table.AtEnd); err != nil {
+			return err
+		}
+	}
+	return t.Write(os.Stdout, table.MarkdownFormatter())
+}
+
+// printDOT prints a graph in Graphviz DOT format, preceded by a comment with
+// the revision of the inventory.
+func printDOT(w io.Writer, g *inventory.Subgraph, rev *Revision) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("dot: %v", r)
+		}
+	}()
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	table.MustFprintf(w, "// inventory: %s\n", rev)
+	table.MustFprintf(w, "digraph inventory {\n")
+	for _, n := range g.Nodes {
+		table.MustFprintf(w, "  %s;\n", quote(n.Ref().String()))
+	}
+	for _, n := range g.Nodes {
+		for _, dep := range g.Edges[n.Ref()] {
+			table.MustFprintf(w, "  %s -> %s;\n", quote(n.Ref().String()), quote(dep.String()))
+		}
+	}
+	table.MustFprintf(w, "}\n")
+	return nil
+}
+
+// printMermaid prints a graph as a Mermaid flowchart, preceded by a comment
+// with the revision of the inventory.
+func printMermaid(w io.Writer, g *inventory.Subgraph, rev *Revision) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mermaid: %v", r)
+		}
+	}()
+	ids := make(map[resource.Ref]string, len(g.Nodes))
+	table.MustFprintf(w, "%%%% inventory: %s\n", rev)
+	table.MustFprintf(w, "flowchart LR\n")
+	for i, n := range g.Nodes {
+		ids[n.Ref()] = fmt.Sprintf("n%d", i)
+		label := strings.ReplaceAll(n.Ref().String(), `"`, "#quot;")
+		table.MustFprintf(w, "  %s[\"%s\"]\n", ids[n.Ref()], label)
+	}
+	for _, n := range g.Nodes {
+		for _, dep := range g.Edges[n.Ref()] {
+			table.MustFprintf(w, "  %s --> %s\n", ids[n.Ref()], ids[dep])
+		}
+	}
+	return nil
+}
+
 // printTable prints entities as a table.
 func printTable(ents []interface{}) error {
+	if len(ents) == 0 {
+		return nil
+	}
 	t := table.New()
 	switch ents[0].(type) {
 	case string:
@@ -72,16 +218,105 @@ func printTable(ents []interface{}) error {
 	return t.Write(os.Stdout, table.MarkdownFormatter())
 }
 
+// printColumns prints resources as a table of the given columns, along with
+// the revision of the inventory. Cells of resources without a value at the
+// column path are left empty.
+func printColumns(resources []*resource.Resource, columns []inventory.Column, rev *Revision) error {
+	t := table.New()
+	for _, c := range columns {
+		t.InsertColumn(c.Header, table.AtEnd)
+	}
+	for _, r := range resources {
+		row := make([]interface{}, len(columns))
+		for i, c := range columns {
+			row[i] = ""
+			if v, ok := r.Field(c.Path); ok {
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		if err := t.InsertRow(row, table.AtEnd); err != nil {
+			return err
+		}
+	}
+	if err := t.Write(os.Stdout, table.MarkdownFormatter()); err != nil {
+		return err
+	}
+	return printRevision(rev)
+}
+
+// printResource prints a single resource, including its data, in various
+// formats, along with the revision of the inventory.
+func printResource(r *resource.Resource, format Format, rev *Revision) error {
+	switch format {
+	case FormatTable:
+		if err := printResourceTable(r); err != nil {
+			return err
+		}
+		return printRevision(rev)
+	case FormatJSON:
+		return printJSON(inventoryOutput{rev, r})
+	case FormatYAML:
+		return printYAML(inventoryOutput{rev, r})
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// printResourceTable prints a single resource as a table of flattened
+// key/value rows.
+func printResourceTable(r *resource.Resource) error {
+	t := table.New()
+	t.InsertColumn("Key", table.AtEnd)
+	t.InsertColumn("Value", table.AtEnd)
+	for _, f := range r.Flatten() {
+		if err := t.InsertRow([]interface{}{f.Key, fmt.Sprint(f.Value)}, table.AtEnd); err != nil {
+			return err
+		}
+	}
+	return t.Write(os.Stdout, table.MarkdownFormatter())
+}
+
+// printRevision prints the revision of the inventory below a table.
+func printRevision(rev *Revision) error {
+	_, err := fmt.Printf("\nInventory: %s\n", rev)
+	return err
+}
+
 // printJSON prints entities as JSON.
-func printJSON(ents []interface{}) error {
+func printJSON(v interface{}) error {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
-	return enc.Encode(ents)
+	return enc.Encode(v)
 }
 
 // printYAML prints entities as YAML.
-func printYAML(ents []interface{}) error {
+func printYAML(v interface{}) error {
 	enc := yaml.NewEncoder(os.Stdout)
 	enc.SetIndent(2)
-	return enc.Encode(ents)
+	return enc.Encode(v)
+}
+
+// groupedErrors formats inventory errors grouped by file.
+type groupedErrors struct {
+	inventory.Errors
+}
+
+// Error implements the error interface.
+func (g groupedErrors) Error() string {
+	b := &strings.Builder{}
+	for i, group := range g.ByFile() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if group.Path == "" {
+			b.WriteString("errors:\n")
+		} else {
+			fmt.Fprintf(b, "%s:\n", group.Path)
+		}
+		for _, err := range group.Errors {
+			msg := strings.TrimPrefix(strings.TrimPrefix(err.Error(), group.Path), ":")
+			fmt.Fprintf(b, "  %s\n", msg)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
@@ -0,0 +1,424 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/git"
+	"github.com/neuralnorthwest/tpology/table"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// repoConfigFile is the name of the configuration file of a repository.
+	repoConfigFile = ".itool.yaml"
+	// defaultProfile is the profile used when none is chosen.
+	defaultProfile = "default"
+	// envPrefix is the prefix of the environment variables overriding the
+	// settings.
+	envPrefix = "ITOOL_"
+)
+
+// settingNames are the names of the settings of the profiles, which are the
+// names of the flags they set.
+var settingNames = []string{"inventory", "inventory-ref", "inventory-local", "git-cache-dir", "format", "columns"}
+
+// ConfigFile is a configuration file of itool, either the user's or the
+// .itool.yaml of a repository.
+type ConfigFile struct {
+	// Profile is the profile used unless one is chosen with --profile or
+	// ITOOL_PROFILE.
+	Profile string `yaml:"profile"`
+	// Profiles are the named profiles.
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named set of settings. Settings left empty are taken from
+// the next configuration layer.
+type Profile struct {
+	// Inventory is the URL to the inventory repository.
+	Inventory string `yaml:"inventory"`
+	// InventoryRef is the branch, tag or commit of the inventory repository.
+	InventoryRef string `yaml:"inventory-ref"`
+	// InventoryLocal is the path to the local inventory repository.
+	InventoryLocal string `yaml:"inventory-local"`
+	// GitCacheDir is the path to the Git cache directory.
+	GitCacheDir string `yaml:"git-cache-dir"`
+	// Format is the output format of the commands with a --format flag.
+	Format string `yaml:"format"`
+	// Columns are the table columns of resource list when listing a kind
+	// without a default column set and without --columns.
+	Columns string `yaml:"columns"`
+}
+
+// values returns the settings of the profile by name.
+func (p Profile) values() map[string]string {
+	return map[string]string{
+		"inventory":       p.Inventory,
+		"inventory-ref":   p.InventoryRef,
+		"inventory-local": p.InventoryLocal,
+		"git-cache-dir":   p.GitCacheDir,
+		"format":          p.Format,
+		"columns":         p.Columns,
+	}
+}
+
+// Setting is the effective value of a setting and where it came from.
+type Setting struct {
+	// Name is the name of the setting.
+	Name string `json:"name" yaml:"name"`
+	// Value is the effective value.
+	Value string `json:"value" yaml:"value"`
+	// Source is where the value came from: a flag, an environment variable,
+	// a configuration file and profile, or the default.
+	Source string `json:"source" yaml:"source"`
+}
+
+// configLayer is a layer of settings under the flags.
+type configLayer struct {
+	// source describes where the settings come from.
+	source string
+	// values are the settings by name. Empty values are not set.
+	values map[string]string
+}
+
+// ConfigViewConfig is the configuration of config view.
+type ConfigViewConfig struct {
+	// Format is the output format.
+	Format string
+}
+
+// settingTargets are the settings that do not set their flag, but a default
+// of their own that the command applies after its other defaults.
+var settingTargets = map[string]*string{
+	"columns": &config.Resource.List.DefaultColumns,
+}
+
+// settings are the effective settings of the running command, set by
+// applyConfig.
+var settings []Setting
+
+// applyConfig sets the flags of a command from the environment and the
+// configuration files, in that order of precedence, unless they are set on
+// the command line. Settings without a flag in the command are only
+// recorded for config view.
+func applyConfig(cmd *cobra.Command) error {
+	files, err := configFiles()
+	if err != nil {
+		return err
+	}
+	profile, err := chooseProfile(cmd, files)
+	if err != nil {
+		return err
+	}
+	layers := []configLayer{{source: "env", values: envSettings()}}
+	for _, f := range files {
+		p, ok := f.file.Profiles[profile.Value]
+		if ok {
+			layers = append(layers, configLayer{
+				source: fmt.Sprintf("%s (profile %s)", f.path, profile.Value),
+				values: p.values(),
+			})
+		}
+	}
+	settings = []Setting{profile}
+	for _, name := range settingNames {
+		s, err := resolveSetting(cmd, name, layers)
+		if err != nil {
+			return err
+		}
+		settings = append(settings, s)
+	}
+	return nil
+}
+
+// resolveSetting returns the effective value of a setting, setting its flag
+// or its target from the first layer defining it unless the flag is set.
+// Formats the command does not support are skipped.
+func resolveSetting(cmd *cobra.Command, name string, layers []configLayer) (Setting, error) {
+	flag := cmd.Flags().Lookup(name)
+	if flag != nil && flag.Changed {
+		return newSetting(name, flag.Value.String(), "flag --"+name), nil
+	}
+	for _, l := range layers {
+		v := l.values[name]
+		if v == "" {
+			continue
+		}
+		if flag != nil && !allowed(flag.Annotations[formatsAnnotation], v) {
+			continue
+		}
+		if target, ok := settingTargets[name]; ok {
+			*target = v
+		} else if flag != nil {
+			if err := flag.Value.Set(v); err != nil {
+				return Setting{}, fmt.Errorf("invalid %s from %s: %w", name, l.source, err)
+			}
+		}
+		source := l.source
+		if source == "env" {
+			source += " " + envName(name)
+		}
+		return newSetting(name, v, source), nil
+	}
+	if flag == nil {
+		return Setting{Name: name, Source: "default"}, nil
+	}
+	return newSetting(name, flag.DefValue, "default"), nil
+}
+
+// newSetting returns a setting, leaving out the password of the inventory
+// URL, which is shown by config view.
+func newSetting(name, value, source string) Setting {
+	if name == "inventory" {
+		value = git.RedactURL(value)
+	}
+	return Setting{Name: name, Value: value, Source: source}
+}
+
+// allowed returns true if a value is one of the allowed values, or if any
+// value is allowed.
+func allowed(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, a := range values {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseProfile returns the profile chosen with --profile, ITOOL_PROFILE or
+// the configuration files, or the default profile. A chosen profile must be
+// defined in a configuration file.
+func chooseProfile(cmd *cobra.Command, files []loadedConfigFile) (Setting, error) {
+	s := Setting{Name: "profile", Value: defaultProfile, Source: "default"}
+	if flag := cmd.Flags().Lookup("profile"); flag != nil && flag.Changed {
+		s.Value, s.Source = flag.Value.String(), "flag --profile"
+	} else if v := os.Getenv(envName("profile")); v != "" {
+		s.Value, s.Source = v, "env "+envName("profile")
+	} else {
+		for _, f := range files {
+			if f.file.Profile != "" {
+				s.Value, s.Source = f.file.Profile, f.path
+				break
+			}
+		}
+	}
+	if s.Source == "default" {
+		return s, nil
+	}
+	for _, f := range files {
+		if _, ok := f.file.Profiles[s.Value]; ok {
+			return s, nil
+		}
+	}
+	return s, fmt.Errorf("unknown profile %q from %s", s.Value, s.Source)
+}
+
+// envSettings returns the settings of the environment.
+func envSettings() map[string]string {
+	values := map[string]string{}
+	for _, name := range settingNames {
+		values[name] = os.Getenv(envName(name))
+	}
+	return values
+}
+
+// envName returns the name of the environment variable of a setting, e.g.
+// ITOOL_INVENTORY_REF.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// loadedConfigFile is a configuration file and its path.
+type loadedConfigFile struct {
+	// path is the path to the file.
+	path string
+	// file is the content of the file.
+	file *ConfigFile
+}
+
+// configFiles returns the configuration files that exist, the repository's
+// first.
+func configFiles() ([]loadedConfigFile, error) {
+	paths := []string{}
+	if path := repoConfigPath(); path != "" {
+		paths = append(paths, path)
+	}
+	if path := userConfigPath(); path != "" {
+		paths = append(paths, path)
+	}
+	files := []loadedConfigFile{}
+	for _, path := range paths {
+		f, err := loadConfigFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, loadedConfigFile{path: path, file: f})
+	}
+	return files, nil
+}
+
+// loadConfigFile loads a configuration file. Unknown settings are errors.
+func loadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &ConfigFile{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// userConfigPath returns the path to the user's configuration file, e.g.
+// ~/.config/itool/config.yaml, or "" if there is no configuration directory.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "itool", "config.yaml")
+}
+
+// repoConfigPath returns the path to the .itool.yaml of the working
+// directory or of its parents up to the top of the Git repository, or "" if
+// there is none.
+func repoConfigPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, repoConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// configCommand returns the config command.
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show the configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(configViewCommand())
+	return cmd
+}
+
+// configViewCommand returns the config view command.
+func configViewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Show the effective settings and where they come from",
+		Long: `Show the effective settings and where they come from.
+
+Settings are taken from the flags, then the ITOOL_* environment variables,
+then the profile of the .itool.yaml of the repository, then the profile of
+the user's configuration file, and otherwise the defaults. The profile is
+chosen with --profile, ITOOL_PROFILE or the profile key of a configuration
+file, and is "default" otherwise. The format setting only applies to the
+commands supporting it. The columns setting applies to resource list of a
+kind without a default column set in the inventory.
+
+  profile: work
+  profiles:
+    work:
+      inventory: https://example.com/org/inventory.git
+      inventory-ref: main
+      format: yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configView()
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	formatFlag(cmd, &config.ConfigView.Format, FormatTable, FormatJSON, FormatYAML)
+	return cmd
+}
+
+// configView prints the effective settings, along with the configuration
+// files and their profiles.
+func configView() error {
+	switch Format(config.ConfigView.Format) {
+	case FormatTable:
+		t := table.New()
+		for _, h := range []string{"Setting", "Value", "Source"} {
+			t.InsertColumn(h, table.AtEnd)
+		}
+		for _, s := range settings {
+			if err := t.InsertRow([]interface{}{s.Name, s.Value, s.Source}, table.AtEnd); err != nil {
+				return err
+			}
+		}
+		if err := t.Write(os.Stdout, table.MarkdownFormatter()); err != nil {
+			return err
+		}
+		files, err := configFiles()
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			profiles := []string{}
+			for name := range f.file.Profiles {
+				profiles = append(profiles, name)
+			}
+			sort.Strings(profiles)
+			fmt.Printf("\nProfiles of %s: %s", f.path, strings.Join(profiles, ", "))
+		}
+		if len(files) > 0 {
+			fmt.Println()
+		}
+		return nil
+	case FormatJSON:
+		return printJSON(settings)
+	case FormatYAML:
+		return printYAML(settings)
+	default:
+		return fmt.Errorf("unknown format: %s", config.ConfigView.Format)
+	}
+}
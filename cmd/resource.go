@@ -15,13 +15,29 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/inventory"
+	"github.com/neuralnorthwest/tpology/query"
+	"github.com/neuralnorthwest/tpology/resource"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 // ResourceConfig is the resource configuration.
 type ResourceConfig struct {
 	// List is the resource list configuration.
 	List ResourceListConfig
+	// Get is the resource get configuration.
+	Get ResourceGetConfig
+	// Create is the resource create configuration.
+	Create ResourceCreateConfig
+	// Edit is the resource edit configuration.
+	Edit ResourceEditConfig
 }
 
 // SetupFlags sets up the flags for the resource command.
@@ -32,11 +48,122 @@ func (c *ResourceConfig) SetupFlags(cmd *cobra.Command) {
 type ResourceListConfig struct {
 	// Format is the output format.
 	Format string
+	// Where is the expression selecting the resources.
+	Where string
+	// Selector is the label selector of the resources.
+	Selector string
+	// SortBy are the comma separated paths to sort the resources by.
+	SortBy string
+	// Limit is the maximum number of resources listed.
+	Limit int
+	// Columns are the comma separated table columns, or the name of a column
+	// set of the kind.
+	Columns string
+	// DefaultColumns are the columns of the profile, used like Columns when
+	// listing a kind without a default column set.
+	DefaultColumns string
 }
 
 // SetupFlags sets up the flags for the resource list command.
 func (c *ResourceListConfig) SetupFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&c.Format, "format", "f", "table", "output format")
+	formatFlag(cmd, &c.Format, FormatTable, FormatJSON, FormatYAML)
+	cmd.Flags().StringVarP(&c.Where, "where", "w", "", "expression selecting the resources, e.g. 'owner == \"platform\" && data.region in [\"us-east\"]'")
+	cmd.Flags().StringVarP(&c.Selector, "selector", "s", "", "label selector, e.g. 'env=prod,tier!=cache'")
+	cmd.Flags().StringVar(&c.SortBy, "sort-by", "", "comma separated paths to sort by, prefixed with - for descending order")
+	cmd.Flags().IntVar(&c.Limit, "limit", 0, "maximum number of resources listed (0 for no limit)")
+	cmd.Flags().StringVarP(&c.Columns, "columns", "c", "", "comma separated table columns, e.g. 'name,IP:data.ip', or a column set of the kind")
+}
+
+// columns returns the table columns of the resource list command. Without the
+// columns flag, the default column set of the kind is used if the inventory
+// declares one, and otherwise the columns of the profile when listing a kind.
+// It returns nil for the standard columns.
+func (c *ResourceListConfig) columns(inv *inventory.Inventory, kind string) ([]inventory.Column, error) {
+	spec := c.Columns
+	if spec == "" {
+		if columns, ok := inv.ColumnSet(kind, inventory.DefaultColumnSet); ok {
+			return columns, nil
+		}
+		if kind == "" || c.DefaultColumns == "" {
+			return nil, nil
+		}
+		spec = c.DefaultColumns
+	}
+	if columns, ok := inv.ColumnSet(kind, spec); ok {
+		return columns, nil
+	}
+	return inventory.ParseColumns(spec)
+}
+
+// query returns the query of the resource list command, or nil if no query
+// flag is set.
+func (c *ResourceListConfig) query() (*query.Query, error) {
+	if c.Where == "" && c.Selector == "" && c.SortBy == "" && c.Limit == 0 {
+		return nil, nil
+	}
+	q, err := query.New(c.Where, c.SortBy, c.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if c.Selector != "" {
+		sel, err := query.ParseSelector(c.Selector)
+		if err != nil {
+			return nil, err
+		}
+		q.Where = query.And(sel, q.Where)
+	}
+	return q, nil
+}
+
+// ResourceGetConfig is the resource get configuration.
+type ResourceGetConfig struct {
+	// Format is the output format.
+	Format string
+}
+
+// SetupFlags sets up the flags for the resource get command.
+func (c *ResourceGetConfig) SetupFlags(cmd *cobra.Command) {
+	formatFlag(cmd, &c.Format, FormatTable, FormatJSON, FormatYAML)
+}
+
+// ResourceCreateConfig is the resource create configuration.
+type ResourceCreateConfig struct {
+	// Description is the description of the resource.
+	Description string
+	// Owner is the owner of the resource.
+	Owner string
+	// Labels are the labels of the resource.
+	Labels map[string]string
+	// Annotations are the annotations of the resource.
+	Annotations map[string]string
+	// Set are the "<path>=<value>" assignments to the resource.
+	Set []string
+	// File is the manifest the resource is added to.
+	File string
+	// Stdin reads the resource from the standard input.
+	Stdin bool
+}
+
+// SetupFlags sets up the flags for the resource create command.
+func (c *ResourceCreateConfig) SetupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&c.Description, "description", "d", "", "description of the resource")
+	cmd.Flags().StringVarP(&c.Owner, "owner", "o", "", "owner of the resource")
+	cmd.Flags().StringToStringVar(&c.Labels, "label", nil, "labels of the resource, e.g. env=prod,tier=db")
+	cmd.Flags().StringToStringVar(&c.Annotations, "annotation", nil, "annotations of the resource")
+	cmd.Flags().StringArrayVar(&c.Set, "set", nil, "set a field to a YAML value, e.g. data.ip=10.0.0.1 (repeatable)")
+	cmd.Flags().StringVar(&c.File, "file", "", "manifest to add the resource to, relative to the inventory (default <kind>/<name>.yaml)")
+	cmd.Flags().BoolVar(&c.Stdin, "stdin", false, "read the resource as a YAML document from the standard input")
+}
+
+// ResourceEditConfig is the resource edit configuration.
+type ResourceEditConfig struct {
+	// Set are the "<path>=<value>" assignments to the resource.
+	Set []string
+}
+
+// SetupFlags sets up the flags for the resource edit command.
+func (c *ResourceEditConfig) SetupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&c.Set, "set", nil, "set a field to a YAML value instead of opening the editor (repeatable)")
 }
 
 // resourceCommand returns the resource command.
@@ -53,6 +180,10 @@ func resourceCommand() *cobra.Command {
 	}
 	config.Resource.SetupFlags(cmd)
 	cmd.AddCommand(resourceListCommand())
+	cmd.AddCommand(resourceGetCommand())
+	cmd.AddCommand(resourceCreateCommand())
+	cmd.AddCommand(resourceEditCommand())
+	cmd.AddCommand(resourceDeleteCommand())
 	return cmd
 }
 
@@ -70,27 +201,405 @@ func resourceListCommand() *cobra.Command {
 		SilenceErrors: true,
 	}
 	config.Resource.List.SetupFlags(cmd)
+	setupAtFlag(cmd)
 	return cmd
 }
 
 // resourceList lists resources.
 func resourceList(args []string) error {
-	inv, err := loadInventory()
+	c := &config.Resource.List
+	q, err := c.query()
+	if err != nil {
+		return err
+	}
+	inv, rev, err := loadInventory()
 	if err != nil {
 		return err
 	}
 	ents := []interface{}{}
-	if len(args) == 0 {
+	if q == nil && len(args) == 0 && c.Columns == "" {
 		for kind := range inv.Resources {
 			ents = append(ents, kind)
 		}
+		return printEntities(ents, Format(c.Format), rev)
+	}
+	if q == nil {
+		q = &query.Query{}
+	}
+	kind := ""
+	var resources []*resource.Resource
+	if len(args) == 0 {
+		resources = inv.Query(q)
 	} else {
-		kind := args[0]
-		if resources, ok := inv.Resources[kind]; ok {
-			for _, r := range resources {
-				ents = append(ents, r)
-			}
+		kind = args[0]
+		for _, r := range inv.Resources[kind] {
+			resources = append(resources, r)
+		}
+		resources = q.Run(resources)
+	}
+	columns, err := c.columns(inv, kind)
+	if err != nil {
+		return err
+	}
+	if columns != nil && Format(c.Format) == FormatTable {
+		return printColumns(resources, columns, rev)
+	}
+	for _, r := range resources {
+		ents = append(ents, r)
+	}
+	return printEntities(ents, Format(c.Format), rev)
+}
+
+// resourceGetCommand returns the resource get command.
+func resourceGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <kind> <name>",
+		Short: "Show a resource",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resourceGet(args)
+		},
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	config.Resource.Get.SetupFlags(cmd)
+	setupAtFlag(cmd)
+	return cmd
+}
+
+// resourceGet shows a single resource.
+func resourceGet(args []string) error {
+	inv, rev, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	kind, name := args[0], args[1]
+	r, ok := inv.Resources[kind][name]
+	if !ok {
+		return fmt.Errorf("resource not found: %s/%s", kind, name)
+	}
+	return printResource(r, Format(config.Resource.Get.Format), rev)
+}
+
+// resourceCreateCommand returns the resource create command.
+func resourceCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create [<kind> <name>]",
+		Short: "Create a resource",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resourceCreate(args)
+		},
+		Args:          cobra.RangeArgs(0, 2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	config.Resource.Create.SetupFlags(cmd)
+	config.Commit.SetupFlags(cmd)
+	return cmd
+}
+
+// resourceCreate creates a resource from the flags, or from the standard
+// input, and appends it to a manifest of the inventory.
+func resourceCreate(args []string) error {
+	c := &config.Resource.Create
+	if err := checkInventoryWritable(); err != nil {
+		return err
+	}
+	r, err := c.resource(args)
+	if err != nil {
+		return err
+	}
+	return changeInventory("create", r.Ref(), func(invPath string) (string, error) {
+		// The mirror of a cached inventory is locked by now, so no one else
+		// creates the resource meanwhile.
+		inv, err := loadInventoryFrom(invPath)
+		if err != nil {
+			return "", err
+		}
+		if existing, ok := inv.Get(r.Ref()); ok {
+			return "", fmt.Errorf("resource already exists: %s at %v", r.Ref(), existing.Position())
+		}
+		path := c.File
+		if path == "" {
+			path = filepath.Join(r.Kind, r.Name+".yaml")
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(invPath, path)
+		}
+		doc, err := r.MarshalDocument()
+		if err != nil {
+			return "", err
+		}
+		m, err := resource.ReadManifest(path)
+		if err != nil {
+			return "", err
+		}
+		m.Append(string(doc))
+		if err := writeManifest(invPath, path, m); err != nil {
+			return "", err
+		}
+		if !config.Global.Quiet {
+			fmt.Printf("created %s in %s\n", r.Ref(), path)
+		}
+		return path, nil
+	})
+}
+
+// resource returns the resource described by the arguments and flags of the
+// resource create command.
+func (c *ResourceCreateConfig) resource(args []string) (*resource.Resource, error) {
+	r := &resource.Resource{}
+	if c.Stdin {
+		resources, err := resource.Load(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		if len(resources) != 1 {
+			return nil, fmt.Errorf("expected one resource on the standard input, got %d", len(resources))
+		}
+		r = resources[0]
+	}
+	switch {
+	case len(args) == 2:
+		if c.Stdin && (r.Kind != args[0] || r.Name != args[1]) {
+			return nil, fmt.Errorf("resource on the standard input is %s, not %s/%s", r.Ref(), args[0], args[1])
+		}
+		if resource.KindIsReservedWord(args[0]) {
+			return nil, fmt.Errorf("%w: %s", resource.ErrorKindIsReservedWord, args[0])
+		}
+		r.Kind, r.Name = args[0], args[1]
+	case !c.Stdin:
+		return nil, fmt.Errorf("kind and name are required unless --stdin is set")
+	case len(args) == 1:
+		return nil, fmt.Errorf("both kind and name are required")
+	}
+	if r.Name == "" {
+		return nil, fmt.Errorf("resource has no name")
+	}
+	if c.Description != "" {
+		r.Description = c.Description
+	}
+	if c.Owner != "" {
+		r.Owner = c.Owner
+	}
+	for k, v := range c.Labels {
+		if err := r.SetField("labels."+k, v); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range c.Annotations {
+		if err := r.SetField("annotations."+k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := setFields(r, c.Set); err != nil {
+		return nil, err
+	}
+	if r.Data == nil {
+		r.Data = map[string]interface{}{}
+	}
+	return r, nil
+}
+
+// setFields applies "<path>=<value>" assignments to a resource. Values are
+// parsed as YAML, so numbers and booleans keep their type.
+func setFields(r *resource.Resource, sets []string) error {
+	for _, set := range sets {
+		path, text, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("invalid assignment, expected <path>=<value>: %s", set)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", path, err)
+		}
+		if err := r.SetField(path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceEditCommand returns the resource edit command.
+func resourceEditCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit <kind> <name>",
+		Short: "Edit a resource in $EDITOR, or set fields with --set",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resourceEdit(args)
+		},
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	config.Resource.Edit.SetupFlags(cmd)
+	config.Commit.SetupFlags(cmd)
+	return cmd
+}
+
+// resourceEdit opens the document of a resource in the editor and writes it
+// back to its manifest if the inventory still loads.
+func resourceEdit(args []string) error {
+	if err := checkInventoryWritable(); err != nil {
+		return err
+	}
+	ref := resource.Ref{Kind: args[0], Name: args[1]}
+	return changeInventory("edit", ref, func(invPath string) (string, error) {
+		m, r, err := findDocument(invPath, ref.Kind, ref.Name)
+		if err != nil {
+			return "", err
+		}
+		if len(config.Resource.Edit.Set) > 0 {
+			return r.LoadedFrom(), resourceSet(invPath, m, r, config.Resource.Edit.Set)
+		}
+		return editDocument(invPath, m, r)
+	})
+}
+
+// editDocument opens the document of a resource in the editor and writes it
+// back to its manifest. It returns the path of the manifest, or "" if the
+// document was not changed. If the edited document cannot be written back,
+// it is kept in a temporary file.
+func editDocument(invPath string, m *resource.Manifest, r *resource.Resource) (string, error) {
+	doc, err := m.Document(r.Index())
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "itool-"+r.Kind+"-"+r.Name+"-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.WriteString(doc)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := runEditor(tmpPath); err != nil {
+		return "", err
+	}
+	edited, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	if string(edited) == doc {
+		os.Remove(tmpPath)
+		if !config.Global.Quiet {
+			fmt.Println("no changes made")
+		}
+		return "", nil
+	}
+	keep := func(err error) (string, error) {
+		fmt.Fprintf(os.Stderr, "your changes were saved to %s\n", tmpPath)
+		return "", err
+	}
+	resources, err := resource.Load(bytes.NewReader(edited))
+	if err != nil {
+		return keep(err)
+	}
+	if len(resources) != 1 {
+		return keep(fmt.Errorf("expected one resource, got %d", len(resources)))
+	}
+	if err := m.Replace(r.Index(), string(edited)); err != nil {
+		return keep(err)
+	}
+	if err := writeManifest(invPath, r.LoadedFrom(), m); err != nil {
+		return keep(err)
+	}
+	os.Remove(tmpPath)
+	if !config.Global.Quiet {
+		fmt.Printf("edited %s in %s\n", resources[0].Ref(), r.LoadedFrom())
+	}
+	return r.LoadedFrom(), nil
+}
+
+// resourceSet applies assignments to a resource and writes its document back
+// through its YAML node, so that only the changed values differ.
+func resourceSet(invPath string, m *resource.Manifest, r *resource.Resource, sets []string) error {
+	if inventory.MergePolicy(config.Global.Merge) == inventory.MergeDeep {
+		return fmt.Errorf("--set cannot write back deep-merged resources")
+	}
+	if err := setFields(r, sets); err != nil {
+		return err
+	}
+	doc, err := r.MarshalDocument()
+	if err != nil {
+		return err
+	}
+	if err := m.Replace(r.Index(), string(doc)); err != nil {
+		return err
+	}
+	if err := writeManifest(invPath, r.LoadedFrom(), m); err != nil {
+		return err
+	}
+	if !config.Global.Quiet {
+		fmt.Printf("edited %s in %s\n", r.Ref(), r.LoadedFrom())
+	}
+	return nil
+}
+
+// resourceDeleteCommand returns the resource delete command.
+func resourceDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "delete <kind> <name>",
+		Aliases: []string{"rm"},
+		Short:   "Delete a resource",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resourceDelete(args)
+		},
+		Args:          cobra.ExactArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	config.Commit.SetupFlags(cmd)
+	return cmd
+}
+
+// resourceDelete removes the document of a resource from its manifest, and
+// the manifest itself if no document is left. Resources still referencing
+// the deleted resource make the deletion fail.
+func resourceDelete(args []string) error {
+	if err := checkInventoryWritable(); err != nil {
+		return err
+	}
+	ref := resource.Ref{Kind: args[0], Name: args[1]}
+	return changeInventory("delete", ref, func(invPath string) (string, error) {
+		m, r, err := findDocument(invPath, ref.Kind, ref.Name)
+		if err != nil {
+			return "", err
+		}
+		if err := m.Delete(r.Index()); err != nil {
+			return "", err
+		}
+		if err := writeManifest(invPath, r.LoadedFrom(), m); err != nil {
+			return "", err
 		}
+		if !config.Global.Quiet {
+			fmt.Printf("deleted %s from %s\n", r.Ref(), r.LoadedFrom())
+		}
+		return r.LoadedFrom(), nil
+	})
+}
+
+// findDocument loads the inventory at invPath and returns the resource along
+// with the manifest it was loaded from.
+func findDocument(invPath, kind, name string) (*resource.Manifest, *resource.Resource, error) {
+	inv, err := loadInventoryFrom(invPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, ok := inv.Get(resource.Ref{Kind: kind, Name: name})
+	if !ok {
+		return nil, nil, fmt.Errorf("resource not found: %s/%s", kind, name)
+	}
+	m, err := resource.ReadManifest(r.LoadedFrom())
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.Index() >= m.Len() {
+		return nil, nil, fmt.Errorf("%w: %v", resource.ErrorDocumentNotFound, r.Position())
 	}
-	return printEntities(ents, Format(config.Resource.List.Format))
+	return m, r, nil
 }
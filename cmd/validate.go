@@ -0,0 +1,53 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// validateCommand returns the validate command.
+func validateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate resources against the schemas of their kinds",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validate()
+		},
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	setupAtFlag(cmd)
+	return cmd
+}
+
+// validate validates the inventory. Loading the inventory validates it, so
+// any violation is returned as an error, even with --keep-going.
+func validate() error {
+	_, rev, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	if inventoryPartial {
+		return errInventoryPartial
+	}
+	if !config.Global.Quiet {
+		fmt.Printf("inventory is valid: %s\n", rev)
+	}
+	return nil
+}
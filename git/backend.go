@@ -0,0 +1,352 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorUnsupported is the error returned when a backend does not support an
+// operation.
+const ErrorUnsupported = Error("operation not supported by the backend")
+
+// Backend performs the Git operations of a Repository.
+type Backend interface {
+	// Clone clones r.URL into r.Dir with r.CloneOptions and checks out
+	// r.MainBranch, a branch or a tag, or the default branch if it is empty.
+	// Args are extra arguments of git clone.
+	Clone(r *Repository, args ...string) error
+	// Clean removes the untracked files of the working tree.
+	Clean(r *Repository) error
+	// Checkout checks out a branch, tag or commit. A branch only known to
+	// the origin remote is created, tracking the remote branch.
+	Checkout(r *Repository, ref string) error
+	// Fetch fetches branches and tags from the origin remote, pruning
+	// deleted branches. Shallow clones only fetch the tags of the fetched
+	// commits.
+	Fetch(r *Repository) error
+	// FastForward fast-forwards the checked out branch to a fully qualified
+	// ref, or to its upstream if ref is empty.
+	FastForward(r *Repository, ref string) error
+	// HasRef returns true if the fully qualified ref exists.
+	HasRef(r *Repository, ref string) bool
+	// IsClean returns true if the working tree has neither changes nor
+	// untracked files.
+	IsClean(r *Repository) bool
+	// CurrentBranch returns the name of the checked out branch, or the commit
+	// hash if HEAD is detached.
+	CurrentBranch(r *Repository) (string, error)
+	// Head returns the hash of the checked out commit.
+	Head(r *Repository) (string, error)
+	// ResolveRef returns the hash of the commit that a branch, tag, commit
+	// hash or revision expression points to. It fails with ErrorRefNotFound
+	// if there is none.
+	ResolveRef(r *Repository, ref string) (string, error)
+	// ResolveRefAt returns the hash of the last commit of the first-parent
+	// history of a ref committed at or before a time. It fails with
+	// ErrorRefNotFound if there is none.
+	ResolveRefAt(r *Repository, ref string, at time.Time) (string, error)
+	// AddWorktree checks out a commit, detached, into a new directory,
+	// leaving the working tree of the repository as is. Only the SparsePaths
+	// of the clone options are checked out, if any.
+	AddWorktree(r *Repository, dir, commit string) error
+	// RemoveWorktree removes a directory added by AddWorktree.
+	RemoveWorktree(r *Repository, dir string) error
+	// RemoteURL returns the URL of the origin remote.
+	RemoteURL(r *Repository) (string, error)
+	// Add stages the changes to the paths, including deletions.
+	Add(r *Repository, paths ...string) error
+	// HasStagedChanges returns true if changes are staged for commit.
+	HasStagedChanges(r *Repository) (bool, error)
+	// Commit commits the staged changes. The author is given as
+	// "Name <email>", or empty for the configured user.
+	Commit(r *Repository, author, message string) error
+	// CreateBranch creates a branch at HEAD and checks it out, keeping
+	// uncommitted changes.
+	CreateBranch(r *Repository, name string) error
+	// DeleteBranch deletes a local branch.
+	DeleteBranch(r *Repository, name string) error
+	// Push pushes a branch to the origin remote and sets it as the upstream
+	// of the branch.
+	Push(r *Repository, branch string) error
+	// PullRebase fetches r.MainBranch, or the upstream of the checked out
+	// branch if it is empty, and rebases the checked out branch onto it.
+	PullRebase(r *Repository) error
+	// Verify checks the connectivity and validity of the objects reachable
+	// from the refs.
+	Verify(r *Repository) error
+	// GC packs the objects and removes the unreachable ones.
+	GC(r *Repository) error
+}
+
+var (
+	// defaultBackend is the backend returned by DefaultBackend.
+	defaultBackend Backend
+	// defaultBackendOnce guards defaultBackend.
+	defaultBackendOnce sync.Once
+)
+
+// DefaultBackend returns the ExecBackend if the git binary is on the PATH,
+// and the in-process GoBackend otherwise.
+func DefaultBackend() Backend {
+	defaultBackendOnce.Do(func() {
+		if _, err := exec.LookPath("git"); err == nil {
+			defaultBackend = ExecBackend{}
+		} else {
+			defaultBackend = GoBackend{}
+		}
+	})
+	return defaultBackend
+}
+
+// ParseBackend returns the backend named "exec", "go", or "auto" for
+// DefaultBackend.
+func ParseBackend(name string) (Backend, error) {
+	switch name {
+	case "", "auto":
+		return DefaultBackend(), nil
+	case "exec":
+		return ExecBackend{}, nil
+	case "go":
+		return GoBackend{}, nil
+	}
+	return nil, fmt.Errorf("unknown git backend %q, expected auto, exec or go", name)
+}
+
+// ExecBackend is the Backend running the git binary. Commands are run
+// through Repository.Exec, so the hooks of the repository apply.
+type ExecBackend struct{}
+
+// Clone implements Backend. Sparse clones are checked out in cone mode.
+// Bare clones fetch the branches as remote branches, as other clones do.
+func (ExecBackend) Clone(r *Repository, args ...string) error {
+	opts := r.CloneOptions
+	cloneArgs := []string{"clone"}
+	if r.Bare {
+		cloneArgs = append(cloneArgs, "--bare", "--config", "remote.origin.fetch=+refs/heads/*:refs/remotes/origin/*")
+	}
+	if r.MainBranch != "" {
+		cloneArgs = append(cloneArgs, "-b", r.MainBranch)
+	}
+	if opts.Depth > 0 {
+		cloneArgs = append(cloneArgs, "--depth", strconv.Itoa(opts.Depth))
+	}
+	if opts.Filter != "" {
+		cloneArgs = append(cloneArgs, "--filter="+opts.Filter)
+	}
+	if len(opts.SparsePaths) > 0 {
+		cloneArgs = append(cloneArgs, "--sparse")
+	}
+	cloneArgs = append(append(cloneArgs, args...), r.URL, r.Dir)
+	if _, err := r.run(r.command(cloneArgs...), false); err != nil {
+		return err
+	}
+	if len(opts.SparsePaths) > 0 {
+		if err := r.Exec(append([]string{"sparse-checkout", "set", "--"}, opts.SparsePaths...)...); err != nil {
+			// A partly checked out clone would pass for a complete one.
+			_ = r.Remove()
+			return err
+		}
+	}
+	return nil
+}
+
+// Clean implements Backend. Ignored files are removed too.
+func (ExecBackend) Clean(r *Repository) error {
+	return r.Exec("clean", "-fdx")
+}
+
+// Checkout implements Backend.
+func (ExecBackend) Checkout(r *Repository, ref string) error {
+	return r.Exec("checkout", ref)
+}
+
+// Fetch implements Backend.
+func (ExecBackend) Fetch(r *Repository) error {
+	if r.CloneOptions.Depth > 0 {
+		// Fetching all tags would fetch their whole history.
+		return r.Exec("fetch", "--prune", "--force", "origin")
+	}
+	return r.Exec("fetch", "--prune", "--tags", "--force", "origin")
+}
+
+// FastForward implements Backend.
+func (ExecBackend) FastForward(r *Repository, ref string) error {
+	if ref == "" {
+		ref = "@{upstream}"
+	}
+	return r.Exec("merge", "--ff-only", ref)
+}
+
+// HasRef implements Backend.
+func (ExecBackend) HasRef(r *Repository, ref string) bool {
+	return r.Exec("rev-parse", "--verify", "--quiet", ref) == nil
+}
+
+// IsClean implements Backend.
+func (ExecBackend) IsClean(r *Repository) bool {
+	out, err := r.ExecOutput("status", "--porcelain")
+	return err == nil && out == ""
+}
+
+// CurrentBranch implements Backend.
+func (ExecBackend) CurrentBranch(r *Repository) (string, error) {
+	out, err := r.ExecOutput("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if branch := strings.TrimSpace(out); branch != "HEAD" {
+		return branch, nil
+	}
+	out, err = r.ExecOutput("rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// Head implements Backend.
+func (ExecBackend) Head(r *Repository) (string, error) {
+	out, err := r.ExecOutput("rev-parse", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// ResolveRef implements Backend.
+func (ExecBackend) ResolveRef(r *Repository, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		// It would be taken for an option.
+		return "", fmt.Errorf("%w: %s", ErrorRefNotFound, ref)
+	}
+	out, err := r.ExecOutput("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if gitErr, ok := err.(*GitError); ok && gitErr.ExitCode == 1 {
+		return "", wrapOp(ErrorRefNotFound, ref, err)
+	}
+	return strings.TrimSpace(out), err
+}
+
+// ResolveRefAt implements Backend.
+func (b ExecBackend) ResolveRefAt(r *Repository, ref string, at time.Time) (string, error) {
+	hash, err := b.ResolveRef(r, ref)
+	if err != nil {
+		return "", err
+	}
+	out, err := r.ExecOutput("rev-list", "-1", "--first-parent", fmt.Sprintf("--before=%d", at.Unix()), hash)
+	if err != nil {
+		return "", err
+	}
+	if out = strings.TrimSpace(out); out == "" {
+		return "", fmt.Errorf("%w: no commit of %s at or before %s", ErrorRefNotFound, ref, at.Format(time.RFC3339))
+	}
+	return out, nil
+}
+
+// AddWorktree implements Backend. The directory is a linked worktree of the
+// repository. A sparse worktree is added without files, and checked out once
+// its sparse paths are set.
+func (ExecBackend) AddWorktree(r *Repository, dir, commit string) error {
+	paths := r.CloneOptions.SparsePaths
+	if len(paths) == 0 {
+		return r.Exec("worktree", "add", "--quiet", "--detach", dir, commit)
+	}
+	if err := r.Exec("worktree", "add", "--quiet", "--detach", "--no-checkout", dir, commit); err != nil {
+		return err
+	}
+	w := *r
+	w.Dir, w.Bare = dir, false
+	if err := w.Exec(append([]string{"sparse-checkout", "set", "--"}, paths...)...); err != nil {
+		return err
+	}
+	return w.Exec("reset", "--quiet", "--hard", "HEAD")
+}
+
+// RemoveWorktree implements Backend.
+func (ExecBackend) RemoveWorktree(r *Repository, dir string) error {
+	if err := r.Exec("worktree", "remove", "--force", dir); err != nil {
+		// The directory may have been removed already.
+		if rerr := r.fs.RemoveAll(dir); rerr != nil {
+			return rerr
+		}
+		return r.Exec("worktree", "prune")
+	}
+	return nil
+}
+
+// RemoteURL implements Backend.
+func (ExecBackend) RemoteURL(r *Repository) (string, error) {
+	out, err := r.ExecOutput("config", "--get", "remote.origin.url")
+	return strings.TrimSpace(out), err
+}
+
+// Add implements Backend.
+func (ExecBackend) Add(r *Repository, paths ...string) error {
+	return r.Exec(append([]string{"add", "--all", "--"}, paths...)...)
+}
+
+// HasStagedChanges implements Backend.
+func (ExecBackend) HasStagedChanges(r *Repository) (bool, error) {
+	err := r.Exec("diff", "--cached", "--quiet")
+	if err == nil {
+		return false, nil
+	}
+	if gitErr, ok := err.(*GitError); ok && gitErr.ExitCode == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
+// Commit implements Backend.
+func (ExecBackend) Commit(r *Repository, author, message string) error {
+	args := []string{"commit", "--quiet", "--message", message}
+	if author != "" {
+		args = append(args, "--author", author)
+	}
+	return r.Exec(args...)
+}
+
+// CreateBranch implements Backend.
+func (ExecBackend) CreateBranch(r *Repository, name string) error {
+	return r.Exec("checkout", "--quiet", "-b", name)
+}
+
+// DeleteBranch implements Backend.
+func (ExecBackend) DeleteBranch(r *Repository, name string) error {
+	return r.Exec("branch", "--quiet", "-D", name)
+}
+
+// Push implements Backend.
+func (ExecBackend) Push(r *Repository, branch string) error {
+	return r.Exec("push", "--quiet", "--set-upstream", "origin", branch)
+}
+
+// PullRebase implements Backend.
+func (ExecBackend) PullRebase(r *Repository) error {
+	args := []string{"pull", "--quiet", "--rebase"}
+	if r.MainBranch != "" {
+		args = append(args, "origin", r.MainBranch)
+	}
+	return r.Exec(args...)
+}
+
+// Verify implements Backend.
+func (ExecBackend) Verify(r *Repository) error {
+	return r.Exec("fsck", "--no-progress", "--no-dangling")
+}
+
+// GC implements Backend.
+func (ExecBackend) GC(r *Repository) error {
+	return r.Exec("gc", "--quiet")
+}
@@ -0,0 +1,289 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ErrorNotCached is the error returned when a repository is not in the
+	// cache.
+	ErrorNotCached = Error("repository not cached")
+	// ErrorRemoteMismatch is the error returned when the origin remote of a
+	// cached repository is not the URL it is cached for.
+	ErrorRemoteMismatch = Error("remote URL does not match the cache")
+	// ErrorVerifyFailed is the error returned when the objects of a
+	// repository are missing or corrupt.
+	ErrorVerifyFailed = Error("git fsck failed")
+	// ErrorGCFailed is the error returned when a repository cannot be
+	// garbage collected.
+	ErrorGCFailed = Error("git gc failed")
+)
+
+// fetchMarker is the file of the Git directory whose modification time is
+// the time of the last clone or fetch.
+const fetchMarker = "itool-fetch"
+
+// RepositoryInfo describes a cached Git repository.
+type RepositoryInfo struct {
+	// URL is the URL of the origin remote, without password.
+	URL string `json:"url" yaml:"url"`
+	// Dir is the path to the directory of the repository.
+	Dir string `json:"dir" yaml:"dir"`
+	// Ref is the checked out branch, or the commit hash if HEAD is detached.
+	Ref string `json:"ref" yaml:"ref"`
+	// Head is the hash of the checked out commit.
+	Head string `json:"head" yaml:"head"`
+	// Size is the size in bytes of the directory.
+	Size int64 `json:"size" yaml:"size"`
+	// LastFetch is the time of the last clone or fetch.
+	LastFetch time.Time `json:"lastFetch" yaml:"lastFetch"`
+	// Mirror is true for the bare mirrors of Cache.Acquire.
+	Mirror bool `json:"mirror,omitempty" yaml:"mirror,omitempty"`
+}
+
+// Repositories returns the repositories of the cache, sorted by directory,
+// followed by the bare mirrors of Acquire. Their URL is the URL of their
+// origin remote, if it can be read. The worktrees of the mirrors are left
+// out; see PruneWorktrees.
+func (c *Cache) Repositories() ([]*Repository, error) {
+	repos := []*Repository{}
+	var walk func(dir string, bare bool) error
+	walk = func(dir string, bare bool) error {
+		entries, err := c.fs.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			// Lock directories and the temporary directories of clones sit
+			// next to the repositories.
+			if !e.IsDir() || strings.HasSuffix(e.Name(), ".lock") || isCloneTemp(e.Name()) {
+				continue
+			}
+			// The mirrors are walked last.
+			if dir == c.CachePath && (e.Name() == mirrorsDir || e.Name() == worktreesDir) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			marker := filepath.Join(path, ".git")
+			if bare {
+				marker = filepath.Join(path, "HEAD")
+			}
+			if _, err := c.fs.Stat(marker); err != nil {
+				if err := walk(path, bare); err != nil {
+					return err
+				}
+				continue
+			}
+			r := &Repository{Dir: path, Bare: bare, Backend: c.Backend, Credentials: c.Credentials, fs: c.fs}
+			if u, err := r.backend().RemoteURL(r); err == nil {
+				r.URL = u
+			}
+			repos = append(repos, r)
+		}
+		return nil
+	}
+	if err := walk(c.CachePath, false); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := walk(filepath.Join(c.CachePath, mirrorsDir), true); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// Remove removes the repository cloned from a URL and its bare mirror, with
+// the worktrees of the mirror and their lock directories, even if a clone is
+// partial. It fails with ErrorLocked if the repository, the mirror or one of
+// its worktrees is in use.
+func (c *Cache) Remove(url string) error {
+	r := c.New(url, "")
+	m := c.Mirror(url)
+	_, err := r.fs.Stat(r.Dir)
+	_, merr := m.fs.Stat(m.Dir)
+	if err != nil && merr != nil {
+		return fmt.Errorf("%w: %s", ErrorNotCached, url)
+	}
+	if err == nil {
+		if err := r.removeLocked(); err != nil {
+			return err
+		}
+	}
+	if merr == nil {
+		return c.removeMirror(m)
+	}
+	return nil
+}
+
+// Prune removes the repositories and mirrors last fetched longer ago than a
+// duration, and returns them. Repositories in use, and mirrors with
+// worktrees in use, are kept.
+func (c *Cache) Prune(olderThan time.Duration) ([]*Repository, error) {
+	repos, err := c.Repositories()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	removed := []*Repository{}
+	for _, r := range repos {
+		if !r.LastFetch().Before(cutoff) {
+			continue
+		}
+		remove := r.removeLocked
+		if r.Bare {
+			remove = func() error { return c.removeMirror(r) }
+		}
+		if err := remove(); errors.Is(err, ErrorLocked) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+		removed = append(removed, r)
+	}
+	return removed, nil
+}
+
+// Verify checks that a repository of the cache is cached for the URL of its
+// origin remote, and that its objects are valid.
+func (c *Cache) Verify(r *Repository) error {
+	u, err := r.backend().RemoteURL(r)
+	if err != nil {
+		return err
+	}
+	want := filepath.Join(c.CachePath, cleanURL(u))
+	if r.Bare {
+		want = c.Mirror(u).Dir
+	}
+	if filepath.Clean(r.Dir) != want {
+		return fmt.Errorf("%w: %s is cached at %s instead of %s", ErrorRemoteMismatch, RedactURL(u), r.Dir, want)
+	}
+	return r.Verify()
+}
+
+// removeLocked removes the repository and its lock directory while holding
+// the exclusive lock.
+func (r *Repository) removeLocked() error {
+	unlock, err := r.TryLock(LockExclusive)
+	if err != nil {
+		return err
+	}
+	err = r.Remove()
+	unlock()
+	// The lock directory is only removed if no one else is waiting on it.
+	_ = r.fs.Remove(r.lockPath())
+	return err
+}
+
+// Info returns the description of the repository. The fields that can be
+// read are filled in even if an error is returned.
+func (r *Repository) Info() (*RepositoryInfo, error) {
+	info := &RepositoryInfo{
+		URL:       RedactURL(r.URL),
+		Dir:       r.Dir,
+		LastFetch: r.LastFetch(),
+		Mirror:    r.Bare,
+	}
+	var errs []string
+	var err error
+	if info.Ref, err = r.CurrentBranch(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if info.Head, err = r.HEAD(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if info.Size, err = r.size(r.Dir); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return info, fmt.Errorf("%s: %s", r.Dir, strings.Join(errs, "; "))
+	}
+	return info, nil
+}
+
+// LastFetch returns the time the repository was last cloned or fetched. For
+// repositories cloned before the time was recorded, it is the time git last
+// fetched it, or else the time of its Git directory.
+func (r *Repository) LastFetch() time.Time {
+	for _, name := range []string{fetchMarker, "FETCH_HEAD", ""} {
+		if info, err := r.fs.Stat(filepath.Join(r.gitDir(), name)); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// markFetched records the time of a clone or fetch. Failing to record it
+// only makes the repository look older to Cache.Prune.
+func (r *Repository) markFetched() {
+	now := time.Now()
+	path := filepath.Join(r.gitDir(), fetchMarker)
+	if err := r.fs.Chtimes(path, now, now); errors.Is(err, os.ErrNotExist) {
+		_ = createFile(r.fs, path)
+	}
+}
+
+// Verify checks the connectivity and validity of the objects of the
+// repository.
+func (r *Repository) Verify() error {
+	if err := r.backend().Verify(r); err != nil {
+		return wrapOp(ErrorVerifyFailed, r.Dir, err)
+	}
+	return nil
+}
+
+// GC packs the objects of the repository and removes the unreachable ones,
+// while holding the exclusive lock.
+func (r *Repository) GC() error {
+	unlock, err := r.TryLock(LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := r.backend().GC(r); err != nil {
+		return wrapOp(ErrorGCFailed, r.Dir, err)
+	}
+	return nil
+}
+
+// size returns the total size of the files under a directory.
+func (r *Repository) size(dir string) (int64, error) {
+	entries, err := r.fs.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() {
+			n, err := r.size(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return 0, err
+			}
+			total += n
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// defaultTokenUsername is the user name sent with a token when none is set.
+// Git hosts authenticate tokens whatever the user name.
+const defaultTokenUsername = "git"
+
+// Credentials authenticate to the remote of a repository. They are passed
+// to git in the environment of its commands, never in its arguments, and are
+// left out of the errors and of the string form of the credentials.
+type Credentials struct {
+	// Username is the user name sent with the token, "git" if empty.
+	Username string
+	// Token is the password or access token of HTTPS remotes. It is sent in
+	// an Authorization header to the host of the remote only.
+	Token string
+	// SSHKey is the path to the private key of SSH remotes.
+	SSHKey string
+	// Helper is a git credential helper, e.g. "store" or "!gh auth
+	// git-credential", used instead of the configured ones. The go-git
+	// backend does not support credential helpers.
+	Helper string
+}
+
+// String returns a description of the credentials without the token.
+func (c *Credentials) String() string {
+	if c == nil {
+		return "none"
+	}
+	parts := []string{}
+	if c.Token != "" {
+		parts = append(parts, "token for "+c.username())
+	}
+	if c.SSHKey != "" {
+		parts = append(parts, "ssh key "+c.SSHKey)
+	}
+	if c.Helper != "" {
+		parts = append(parts, "credential helper "+c.Helper)
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GoString returns the string form of the credentials, so that %#v does not
+// print the token either.
+func (c *Credentials) GoString() string {
+	return c.String()
+}
+
+// username returns the user name sent with the token.
+func (c *Credentials) username() string {
+	if c.Username != "" {
+		return c.Username
+	}
+	return defaultTokenUsername
+}
+
+// apply adds the credentials for the remote at a URL to the environment of
+// a git command, as configuration through GIT_CONFIG_COUNT and
+// GIT_SSH_COMMAND. The token is only sent to the host of an HTTP URL, so
+// none is sent if the URL is empty.
+func (c *Credentials) apply(cmd *exec.Cmd, url string) {
+	if c == nil {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if c.Token != "" {
+		if prefix := httpPrefix(url); prefix != "" {
+			cmd.Env = addConfigEnv(cmd.Env, "http."+prefix+".extraHeader", "Authorization: Basic "+c.basic())
+		}
+	}
+	if c.SSHKey != "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -i "+shellQuote(c.SSHKey)+" -o IdentitiesOnly=yes")
+	}
+	if c.Helper != "" {
+		// An empty helper clears the configured ones.
+		cmd.Env = addConfigEnv(cmd.Env, "credential.helper", "")
+		cmd.Env = addConfigEnv(cmd.Env, "credential.helper", c.Helper)
+	}
+}
+
+// basic returns the token in the form of HTTP basic authentication.
+func (c *Credentials) basic() string {
+	return base64.StdEncoding.EncodeToString([]byte(c.username() + ":" + c.Token))
+}
+
+// redact replaces the token in a message.
+func (c *Credentials) redact(s string) string {
+	if c == nil || c.Token == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, c.Token, "***")
+	return strings.ReplaceAll(s, c.basic(), "***")
+}
+
+// goAuth returns the go-git authentication for the remote at a URL, or nil
+// if the credentials have none for it.
+func (c *Credentials) goAuth(url string) (transport.AuthMethod, error) {
+	if c == nil {
+		return nil, nil
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case (endpoint.Protocol == "http" || endpoint.Protocol == "https") && c.Token != "":
+		return &githttp.BasicAuth{Username: c.username(), Password: c.Token}, nil
+	case endpoint.Protocol == "ssh" && c.SSHKey != "":
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		auth, err := gitssh.NewPublicKeysFromFile(user, c.SSHKey, "")
+		if err != nil {
+			return nil, fmt.Errorf("loading ssh key %s: %w", c.SSHKey, err)
+		}
+		return auth, nil
+	case c.Helper != "":
+		return nil, fmt.Errorf("%w: credential helpers", ErrorUnsupported)
+	}
+	return nil, nil
+}
+
+// httpPrefix returns the scheme and host of an HTTP URL, the prefix that
+// scopes git configuration to its remote, or "" if the URL is not an HTTP
+// one.
+func httpPrefix(url string) string {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || (endpoint.Protocol != "http" && endpoint.Protocol != "https") {
+		return ""
+	}
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host += ":" + strconv.Itoa(endpoint.Port)
+	}
+	return endpoint.Protocol + "://" + host + "/"
+}
+
+// addConfigEnv adds a git configuration entry to an environment, after the
+// entries it already has.
+func addConfigEnv(env []string, key, value string) []string {
+	n := 0
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_CONFIG_COUNT=") {
+			n, _ = strconv.Atoi(strings.TrimPrefix(kv, "GIT_CONFIG_COUNT="))
+		}
+	}
+	return append(env,
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, key),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, value),
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+	)
+}
+
+// shellQuote quotes a word for the shell running GIT_SSH_COMMAND.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// userinfoPassword matches the password of the URLs in a message.
+var userinfoPassword = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]*):[^/\s@]*@`)
+
+// redactURLs removes the passwords of the URLs in a message, as RedactURL
+// does for a single URL.
+func redactURLs(s string) string {
+	return userinfoPassword.ReplaceAllString(s, "${1}@")
+}
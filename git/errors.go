@@ -0,0 +1,197 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Error is an error of a Git operation.
+type Error string
+
+// Error returns the error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	// ErrorNothingToCommit is the error returned when a commit has no staged
+	// changes.
+	ErrorNothingToCommit = Error("nothing to commit")
+	// ErrorBranchExists is the error returned when a branch to create already
+	// exists.
+	ErrorBranchExists = Error("branch already exists")
+	// ErrorAddFailed is the error returned when changes cannot be staged.
+	ErrorAddFailed = Error("git add failed")
+	// ErrorCommitFailed is the error returned when a commit cannot be made.
+	ErrorCommitFailed = Error("git commit failed")
+	// ErrorBranchFailed is the error returned when a branch cannot be created.
+	ErrorBranchFailed = Error("git branch failed")
+	// ErrorPushFailed is the error returned when a branch cannot be pushed.
+	ErrorPushFailed = Error("git push failed")
+	// ErrorPullFailed is the error returned when a branch cannot be pulled.
+	ErrorPullFailed = Error("git pull failed")
+	// ErrorLocked is the error returned when a lock is held by another
+	// process.
+	ErrorLocked = Error("repository already locked")
+
+	// ErrorAuthFailed is the error returned when the remote rejects the
+	// credentials, or none are available.
+	ErrorAuthFailed = Error("authentication failed")
+	// ErrorRefNotFound is the error returned when a branch, tag or revision
+	// does not exist.
+	ErrorRefNotFound = Error("ref not found")
+	// ErrorNotRepository is the error returned when a directory or remote is
+	// not a Git repository.
+	ErrorNotRepository = Error("not a git repository")
+	// ErrorNetworkUnreachable is the error returned when the remote cannot be
+	// reached.
+	ErrorNetworkUnreachable = Error("network unreachable")
+	// ErrorMergeConflict is the error returned when a merge or rebase stops on
+	// conflicts.
+	ErrorMergeConflict = Error("merge conflict")
+)
+
+// classifiers map the stderr of failed Git commands to sentinel errors. The
+// first match wins, so that e.g. an HTTP 403 is an authentication failure
+// rather than a network failure.
+var classifiers = []struct {
+	err     Error
+	pattern *regexp.Regexp
+}{
+	{ErrorAuthFailed, regexp.MustCompile(`(?i)authentication failed|could not read (username|password)|permission denied \(publickey|terminal prompts disabled|invalid username or password|returned error: 40[13]|host key verification failed`)},
+	{ErrorNotRepository, regexp.MustCompile(`(?i)not a git repository|does not appear to be a git repository|repository '[^']*' (not found|does not exist)|repository not found`)},
+	{ErrorNetworkUnreachable, regexp.MustCompile(`(?i)could not resolve host|connection refused|connection timed out|operation timed out|network is unreachable|no route to host|failed to connect|could not read from remote repository`)},
+	{ErrorMergeConflict, regexp.MustCompile(`(?i)conflict|could not apply|unmerged paths|needs merge`)},
+	{ErrorRefNotFound, regexp.MustCompile(`(?i)couldn't find remote ref|unknown revision|did not match any file\(s\) known to git|not a valid object name|remote branch \S+ not found|invalid reference|needed a single revision|not a valid ref`)},
+}
+
+// GitError is the error of a Git command that exited with a non-zero status.
+// It matches the sentinel error classifying its stderr, e.g.
+// errors.Is(err, ErrorAuthFailed), and unwraps to the *exec.ExitError.
+type GitError struct {
+	// Args are the arguments of the command, without "git".
+	Args []string
+	// ExitCode is the exit code of the command.
+	ExitCode int
+	// Stderr is the standard error output of the command.
+	Stderr string
+	// Kind is the sentinel error classifying the failure, or nil.
+	Kind error
+	// err is the error returned by the command.
+	err error
+}
+
+// newGitError returns a GitError for the command if err is an exit error,
+// and err otherwise.
+func newGitError(cmd *exec.Cmd, stderr string, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	e := &GitError{
+		Args:     cmd.Args[1:],
+		ExitCode: exitErr.ExitCode(),
+		Stderr:   stderr,
+		err:      err,
+	}
+	for _, c := range classifiers {
+		if c.pattern.MatchString(stderr) {
+			e.Kind = c.err
+			break
+		}
+	}
+	return e
+}
+
+// Error returns the command, its exit status and the messages of its stderr,
+// without the passwords of the URLs they mention.
+func (e *GitError) Error() string {
+	msg := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.err)
+	if text := e.message(); text != "" {
+		msg += ": " + text
+	}
+	return redactURLs(msg)
+}
+
+// message returns the fatal and error lines of stderr, or all of it if it
+// has none.
+func (e *GitError) message() string {
+	lines := []string{}
+	all := []string{}
+	for _, line := range strings.Split(e.Stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		all = append(all, line)
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		lines = all
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Is returns true if the target is the sentinel error classifying the
+// failure.
+func (e *GitError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap returns the error returned by the command.
+func (e *GitError) Unwrap() error {
+	return e.err
+}
+
+// opError is the error of a Git operation. It matches both the sentinel
+// error of the operation and the errors of the failed command.
+type opError struct {
+	// op is the sentinel error of the operation.
+	op Error
+	// detail is what the operation was applied to, if anything.
+	detail string
+	// err is the error of the command.
+	err error
+}
+
+// wrapOp returns an error matching op and err.
+func wrapOp(op Error, detail string, err error) error {
+	return &opError{op: op, detail: detail, err: err}
+}
+
+// Error returns the error message.
+func (e *opError) Error() string {
+	if e.detail == "" {
+		return fmt.Sprintf("%s: %v", e.op, e.err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.op, e.detail, e.err)
+}
+
+// Is returns true if the target is the sentinel error of the operation.
+func (e *opError) Is(target error) bool {
+	return target == e.op
+}
+
+// Unwrap returns the error of the command.
+func (e *opError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_GitError_Classify tests the classification of the stderr of failed
+// Git commands.
+func Test_GitError_Classify(t *testing.T) {
+	t.Parallel()
+	cases := map[string]error{
+		"fatal: Authentication failed for 'https://example.com/repo.git/'":                               ErrorAuthFailed,
+		"fatal: could not read Username for 'https://example.com': terminal prompts disabled":            ErrorAuthFailed,
+		"git@example.com: Permission denied (publickey).\nfatal: Could not read from remote repository.": ErrorAuthFailed,
+		"fatal: unable to access 'https://example.com/': The requested URL returned error: 403":          ErrorAuthFailed,
+		"fatal: not a git repository (or any of the parent directories): .git":                           ErrorNotRepository,
+		"remote: Repository not found.\nfatal: repository 'https://example.com/x.git/' not found":        ErrorNotRepository,
+		"fatal: unable to access 'https://example.com/': Could not resolve host: example.com":            ErrorNetworkUnreachable,
+		"fatal: unable to access 'http://127.0.0.1:1/': Failed to connect to 127.0.0.1 port 1":           ErrorNetworkUnreachable,
+		"CONFLICT (content): Merge conflict in hosts.yaml\nerror: could not apply 1234567... edit":       ErrorMergeConflict,
+		"fatal: couldn't find remote ref nonexistent":                                                    ErrorRefNotFound,
+		"error: pathspec 'nonexistent' did not match any file(s) known to git":                           ErrorRefNotFound,
+		"fatal: ambiguous argument 'nonexistent': unknown revision or path not in the working tree.":     ErrorRefNotFound,
+		"fatal: something else went wrong":                                                               nil,
+	}
+	for stderr, want := range cases {
+		cmd := exec.Command("false")
+		err := newGitError(cmd, stderr, cmd.Run())
+		var gitErr *GitError
+		assert.True(t, errors.As(err, &gitErr), stderr)
+		assert.Equal(t, want, gitErr.Kind, stderr)
+		if want != nil {
+			assert.ErrorIs(t, err, want, stderr)
+		}
+	}
+	// Other errors are returned as-is.
+	other := errors.New("other")
+	assert.Equal(t, other, newGitError(exec.Command("git"), "", other))
+	assert.Nil(t, newGitError(exec.Command("git"), "", nil))
+}
+
+// Test_GitError_Exec tests the errors returned by Exec.
+func Test_GitError_Exec(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	err := repo.Checkout("nonexistent")
+	var gitErr *GitError
+	assert.True(t, errors.As(err, &gitErr))
+	assert.Equal(t, []string{"-C", repo.Dir, "checkout", "nonexistent"}, gitErr.Args)
+	assert.Equal(t, 1, gitErr.ExitCode)
+	assert.Contains(t, gitErr.Stderr, "nonexistent")
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+	assert.Contains(t, err.Error(), "did not match any file(s) known to git")
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(err, &exitErr))
+
+	_, err = Open(t.TempDir()).ExecOutput("status")
+	assert.ErrorIs(t, err, ErrorNotRepository)
+	assert.Contains(t, err.Error(), "fatal: not a git repository")
+}
+
+// Test_GitError_Clone tests the errors returned by Clone.
+func Test_GitError_Clone(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	err := c.New(filepath.Join(t.TempDir(), "missing"), "main").Clone()
+	assert.ErrorIs(t, err, ErrorNotRepository)
+	err = c.New("http://127.0.0.1:1/repo.git", "main").Clone()
+	assert.ErrorIs(t, err, ErrorNetworkUnreachable)
+}
+
+// Test_GitError_PullRebase_Conflict tests that a conflicting rebase is both
+// a pull failure and a merge conflict.
+func Test_GitError_PullRebase_Conflict(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	setUser(t, repo)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.URL, "test.txt"), []byte("origin\n"), 0644))
+	mustExecLog(t, "git", "-C", repo.URL, "add", "test.txt")
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "-m", "origin")
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "test.txt"), []byte("local\n"), 0644))
+	assert.NoError(t, repo.Add("test.txt"))
+	assert.NoError(t, repo.Commit("", "local"))
+	err := repo.PullRebase()
+	assert.ErrorIs(t, err, ErrorPullFailed)
+	assert.ErrorIs(t, err, ErrorMergeConflict)
+}
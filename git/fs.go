@@ -14,7 +14,10 @@
 
 package git
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 // fs lets us mock some filesystem operations for testing.
 type fs interface {
@@ -32,11 +35,26 @@ type fs interface {
 	Remove(name string) error
 	// Open opens a file.
 	Open(name string) (*os.File, error)
+	// ReadDir reads the entries of a directory.
+	ReadDir(name string) ([]os.DirEntry, error)
+	// Rename renames a file or directory.
+	Rename(oldpath, newpath string) error
+	// Chtimes changes the access and modification times of a file.
+	Chtimes(name string, atime, mtime time.Time) error
 }
 
 // generate mocks with gomock
 //go:generate go run github.com/golang/mock/mockgen@v1.6.0 -package mock_git -source=fs.go -destination mock_git/fs_mock.go . fs
 
+// createFile creates an empty file, truncating it if it exists.
+func createFile(f fs, name string) error {
+	file, err := f.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 // osFS implements fsMock using the os package.
 type osFS struct{}
 
@@ -74,3 +92,18 @@ func (*osFS) Remove(name string) error {
 func (*osFS) Open(name string) (*os.File, error) {
 	return os.Open(name)
 }
+
+// ReadDir reads the entries of a directory.
+func (*osFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Rename renames a file or directory.
+func (*osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Chtimes changes the access and modification times of a file.
+func (*osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
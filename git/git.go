@@ -15,36 +15,83 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Cache is the Git cache.
 type Cache struct {
 	// CachePath is the path to the Git cache directory.
 	CachePath string
+	// Backend is the backend of the repositories of the cache. If nil,
+	// DefaultBackend is used.
+	Backend Backend
+	// CloneOptions are the clone options of the repositories of the cache.
+	CloneOptions CloneOptions
+	// Credentials authenticate the repositories of the cache to their
+	// remotes, or nil for those of the git configuration.
+	Credentials *Credentials
 	// fs is the filesystem interface.
 	fs
 }
 
+// CloneOptions are the options of a clone, to fetch and check out less of a
+// large repository. They apply when the repository is cloned, and later
+// fetches only add the new commits.
+type CloneOptions struct {
+	// Depth is the number of commits fetched from the tip of each ref, or
+	// zero for the whole history.
+	Depth int
+	// Filter is the partial clone filter, e.g. blob:none to fetch file
+	// contents on demand. The go-git backend ignores it and fetches all
+	// objects.
+	Filter string
+	// SparsePaths are the directories checked out, along with the files at
+	// the top of the repository. If empty, everything is checked out.
+	SparsePaths []string
+}
+
 // Repository represents a local clone of a Git repository.
 type Repository struct {
 	// URL is the URL of the Git repository.
 	URL string
-	// MainBranch is the name of the main branch of the Git repository.
+	// MainBranch is the name of the main branch of the Git repository. It
+	// may also be a tag or a commit hash, checked out detached.
 	MainBranch string
 	// Dir is the path to the directory where the Git repository is cloned.
 	Dir string
-	// PreHook is a function that is called before a Git command is executed.
+	// PreHook is a function that is called before a Git command is executed,
+	// with the credentials already in the environment of the command.
 	PreHook func(*exec.Cmd) error
 	// PostHook is a function that is called after a Git command is executed.
 	PostHook func(*exec.Cmd, error) error
+	// Backend performs the Git operations. If nil, DefaultBackend is used.
+	// The hooks are only called by backends running the git binary.
+	Backend Backend
+	// CloneOptions are the options of the clone of the repository.
+	CloneOptions CloneOptions
+	// LockPath is the path to the lock directory of the repository. If empty,
+	// it is Dir with a ".lock" suffix, outside of the working tree.
+	LockPath string
+	// Bare is true if the repository has no working tree, such as the
+	// mirrors of the cache. Its Git directory is Dir.
+	Bare bool
+	// Credentials authenticate to the origin remote, or nil for those of
+	// the git configuration.
+	Credentials *Credentials
+	// ctx is the context of the Git operations, set by WithContext.
+	ctx context.Context
 	// fs is the filesystem interface.
 	fs
 }
@@ -57,13 +104,19 @@ func NewCache(cachePath string) *Cache {
 	}
 }
 
-// cleanURL cleans the URL of the Git repository.
-func cleanURL(u string) string {
-	if parsed, err := url.Parse(u); err == nil {
-		// Strip the password, if any
+// RedactURL returns the URL of a Git repository without its password, if
+// any, for use in paths and messages.
+func RedactURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.User != nil {
 		parsed.User = url.User(parsed.User.Username())
 		u = parsed.String()
 	}
+	return u
+}
+
+// cleanURL cleans the URL of the Git repository.
+func cleanURL(u string) string {
+	u = RedactURL(u)
 	u = regexp.MustCompile(`[:@]`).ReplaceAllString(u, "/")
 	elems := strings.Split(u, "/")
 	cleanElems := []string{}
@@ -76,19 +129,51 @@ func cleanURL(u string) string {
 	return strings.Join(cleanElems, "/")
 }
 
+const (
+	// cloneMarker is the file of the Git directory marking a complete clone.
+	cloneMarker = "itool-clone"
+	// cloneTempInfix follows the hidden name of the repository directory in
+	// the names of the temporary directories of clones.
+	cloneTempInfix = ".clone-"
+)
+
+// commitHash matches full and abbreviated commit hashes.
+var commitHash = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
 // New returns a new Git repository.
 func (c *Cache) New(url, mainBranch string) *Repository {
 	return &Repository{
-		URL:        url,
-		MainBranch: mainBranch,
-		Dir:        filepath.Join(c.CachePath, cleanURL(url)),
-		fs:         c.fs,
+		URL:          url,
+		MainBranch:   mainBranch,
+		Dir:          filepath.Join(c.CachePath, cleanURL(url)),
+		Backend:      c.Backend,
+		CloneOptions: c.CloneOptions,
+		Credentials:  c.Credentials,
+		fs:           c.fs,
 	}
 }
 
-// isCloned returns true if the Git repository is cloned.
+// Open returns the Git repository checked out at a directory, such as a
+// local inventory. Its main branch is the branch checked out.
+func Open(dir string) *Repository {
+	return &Repository{
+		Dir: dir,
+		fs:  &osFS{},
+	}
+}
+
+// IsCloned returns true if the Git repository is cloned. Clones are complete
+// once they have the completion marker. Checkouts made otherwise, such as
+// local inventories or clones of earlier versions, are cloned if HEAD
+// points to a commit.
 func (r *Repository) IsCloned() bool {
-	_, err := r.fs.Stat(r.Dir)
+	if _, err := r.fs.Stat(filepath.Join(r.gitDir(), cloneMarker)); err == nil {
+		return true
+	}
+	if _, err := r.fs.Stat(r.Dir); err != nil {
+		return false
+	}
+	_, err := r.HEAD()
 	return err == nil
 }
 
@@ -100,33 +185,130 @@ func (r *Repository) CloneIfNotCloned() error {
 	return nil
 }
 
-// Clone clones the Git repository.
+// WithContext returns a shallow copy of the repository whose Git operations
+// are canceled when the context is done. Git commands are killed, and the
+// go-git backend stops the operations that take a context.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	c := *r
+	c.ctx = ctx
+	return &c
+}
+
+// context returns the context of the Git operations.
+func (r *Repository) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// Clone clones the Git repository with its CloneOptions while holding the
+// exclusive lock, failing with ErrorLocked if another process holds it. The
+// lock directory sits next to Dir, so it is keyed by the cleaned URL and
+// exists before the clone. The clone is made in a temporary directory next
+// to Dir and renamed into place once complete, so that no one sees a partial
+// clone. Args are extra arguments of git clone, which only the exec backend
+// supports.
 func (r *Repository) Clone(args ...string) error {
-	if !r.IsCloned() {
-		if err := r.fs.MkdirAll(filepath.Dir(r.Dir), 0755); err != nil {
+	if r.IsCloned() {
+		return fmt.Errorf("repository already cloned: %s", RedactURL(r.URL))
+	}
+	parent := filepath.Dir(r.Dir)
+	if err := r.fs.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	unlock, err := r.TryLock(LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Another process may have cloned the repository meanwhile.
+	if r.IsCloned() {
+		return fmt.Errorf("repository already cloned: %s", RedactURL(r.URL))
+	}
+	if err := r.removePartialClones(); err != nil {
+		return err
+	}
+	tmp, err := r.fs.MkdirTemp(parent, "."+filepath.Base(r.Dir)+cloneTempInfix)
+	if err != nil {
+		return err
+	}
+	clone := *r
+	clone.Dir = tmp
+	err = r.backend().Clone(&clone, args...)
+	if errors.Is(err, ErrorRefNotFound) && commitHash.MatchString(r.MainBranch) {
+		err = clone.cloneCommit(args...)
+	}
+	if err == nil {
+		err = createFile(r.fs, filepath.Join(clone.gitDir(), cloneMarker))
+	}
+	if err == nil {
+		err = r.fs.Rename(tmp, r.Dir)
+	}
+	if err != nil {
+		_ = r.fs.RemoveAll(tmp)
+		return err
+	}
+	r.markFetched()
+	return nil
+}
+
+// CloneContext is Clone with a context. If the context is done before the
+// clone completes, the clone is stopped and its temporary directory removed.
+func (r *Repository) CloneContext(ctx context.Context, args ...string) error {
+	return r.WithContext(ctx).Clone(args...)
+}
+
+// isCloneTemp returns true if a directory name is the name of the temporary
+// directory of a clone.
+func isCloneTemp(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, cloneTempInfix)
+}
+
+// removePartialClones removes what is left of clones that did not complete:
+// the directory of the repository without the completion marker, and the
+// temporary directories of the clones. It must be called while holding the
+// exclusive lock.
+func (r *Repository) removePartialClones() error {
+	if _, err := r.fs.Stat(r.Dir); err == nil {
+		if err := r.fs.RemoveAll(r.Dir); err != nil {
 			return err
 		}
-		var cmd *exec.Cmd
-		if r.MainBranch == "" {
-			cmd = exec.Command("git", append(append([]string{"clone"}, args...), r.URL, r.Dir)...)
-		} else {
-			cmd = exec.Command("git", append(append([]string{"clone", "-b", r.MainBranch}, args...), r.URL, r.Dir)...)
-		}
-		if r.PreHook != nil {
-			if herr := r.PreHook(cmd); herr != nil {
-				return herr
-			}
-		}
-		err := cmd.Run()
-		if r.PostHook != nil {
-			if herr := r.PostHook(cmd, err); herr != nil {
-				return herr
+	}
+	entries, err := r.fs.ReadDir(filepath.Dir(r.Dir))
+	if err != nil {
+		return err
+	}
+	prefix := "." + filepath.Base(r.Dir) + cloneTempInfix
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) {
+			if err := r.fs.RemoveAll(filepath.Join(filepath.Dir(r.Dir), e.Name())); err != nil {
+				return err
 			}
 		}
+	}
+	return nil
+}
+
+// cloneCommit clones the Git repository and checks out the commit hash of
+// the main branch, detached. Servers only send the tips of refs, so the
+// whole history is cloned to be sure to have the commit.
+func (r *Repository) cloneCommit(args ...string) error {
+	clone := *r
+	clone.MainBranch = ""
+	clone.CloneOptions.Depth = 0
+	if err := r.backend().Clone(&clone, args...); err != nil {
+		return err
+	}
+	hash, err := r.ResolveRef(r.MainBranch)
+	if err == nil {
+		err = r.Checkout(hash)
+	}
+	if err != nil {
+		_ = r.Remove()
 		return err
-	} else {
-		return fmt.Errorf("repository already cloned: %s", r.URL)
 	}
+	return nil
 }
 
 // Remove removes the Git repository.
@@ -136,88 +318,370 @@ func (r *Repository) Remove() error {
 
 // Clean cleans the Git repository.
 func (r *Repository) Clean() error {
-	return r.Exec("clean", "-fdx")
+	return r.backend().Clean(r)
 }
 
 // Checkout checks out the specified branch of the Git repository.
 func (r *Repository) Checkout(branch string) error {
-	return r.Exec("checkout", branch)
+	return r.backend().Checkout(r, branch)
 }
 
-// IsClean returns true if the Git repository is clean.
-func (r *Repository) IsClean() bool {
-	out, err := r.ExecOutput("status", "--porcelain")
-	return err == nil && out == ""
+// CheckoutContext is Checkout with a context.
+func (r *Repository) CheckoutContext(ctx context.Context, branch string) error {
+	return r.WithContext(ctx).Checkout(branch)
 }
 
-// Lock locks the Git repository. It returns an unlock function and an error.
-func (r *Repository) Lock() (func(), error) {
-	// Exclusive create the lock file and write our PID to it
-	lockFile := filepath.Join(r.Dir, ".lock")
-	if f, err := r.fs.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err == nil {
-		defer f.Close()
-		if _, err := f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
-			return nil, err
-		}
-		return func() {
-			_ = r.fs.Remove(lockFile)
-		}, nil
-	} else {
-		otherPID := 0
-		if f, err := r.fs.Open(lockFile); err == nil {
-			if _, err := fmt.Fscanf(f, "%d", &otherPID); err == nil {
-				f.Close()
-			}
-		}
-		return nil, fmt.Errorf("repository already locked by PID %d: %s", otherPID, r.URL)
+// Fetch fetches branches and tags from the origin remote of the Git
+// repository.
+func (r *Repository) Fetch() error {
+	if err := r.backend().Fetch(r); err != nil {
+		return err
 	}
+	r.markFetched()
+	return nil
 }
 
-// LockerPID returns the PID of the process that locked the Git repository.
-func (r *Repository) LockerPID() (int, error) {
-	lockFile := filepath.Join(r.Dir, ".lock")
-	if f, err := r.fs.Open(lockFile); err == nil {
-		defer f.Close()
-		var pid int
-		if _, err := fmt.Fscanf(f, "%d", &pid); err == nil {
-			return pid, nil
+// Update fetches the Git repository and fast-forwards the main branch to the
+// origin remote. If the main branch is a tag or a commit hash, the commit it
+// resolves to is checked out detached.
+func (r *Repository) Update() error {
+	if err := r.Fetch(); err != nil {
+		return err
+	}
+	if r.MainBranch == "" {
+		return r.backend().FastForward(r, "")
+	}
+	remote := "refs/remotes/origin/" + r.MainBranch
+	if !r.HasRef("refs/heads/"+r.MainBranch) && !r.HasRef(remote) {
+		hash, err := r.ResolveRef(r.MainBranch)
+		if err != nil {
+			return err
 		}
+		return r.Checkout(hash)
+	}
+	if err := r.Checkout(r.MainBranch); err != nil {
+		return err
 	}
-	return 0, fmt.Errorf("repository not locked: %s", r.URL)
+	if !r.HasRef(remote) {
+		return nil
+	}
+	return r.backend().FastForward(r, remote)
 }
 
-// Exec executes a command in the Git repository.
-func (r *Repository) Exec(args ...string) error {
-	args = append([]string{"-C", r.Dir}, args...)
-	cmd := exec.Command("git", args...)
-	if r.PreHook != nil {
-		if herr := r.PreHook(cmd); herr != nil {
-			return herr
-		}
+// IsUpToDate returns true if Update would leave the checkout as is after the
+// last fetch: the main branch is checked out at the commit of its origin
+// branch, or HEAD is at the tag or commit it names. It is false if the main
+// branch is empty.
+func (r *Repository) IsUpToDate() bool {
+	if r.MainBranch == "" {
+		return false
 	}
-	err := cmd.Run()
-	if r.PostHook != nil {
-		if herr := r.PostHook(cmd, err); herr != nil {
-			return herr
+	head, err := r.HEAD()
+	if err != nil {
+		return false
+	}
+	remote := "refs/remotes/origin/" + r.MainBranch
+	if r.HasRef(remote) || r.HasRef("refs/heads/"+r.MainBranch) {
+		if branch, err := r.CurrentBranch(); err != nil || branch != r.MainBranch {
+			return false
+		}
+		if !r.HasRef(remote) {
+			return true
 		}
+		target, err := r.ResolveRef(remote)
+		return err == nil && target == head
 	}
+	target, err := r.ResolveRef(r.MainBranch)
+	return err == nil && target == head
+}
+
+// HasRef returns true if the fully qualified ref exists in the Git repository.
+func (r *Repository) HasRef(ref string) bool {
+	return r.backend().HasRef(r, ref)
+}
+
+// HEAD returns the hash of the checked out commit.
+func (r *Repository) HEAD() (string, error) {
+	return r.backend().Head(r)
+}
+
+// ResolveRef returns the hash of the commit that a branch, tag, commit hash
+// or revision expression such as main~2 points to. It fails with
+// ErrorRefNotFound if there is none.
+func (r *Repository) ResolveRef(ref string) (string, error) {
+	return r.backend().ResolveRef(r, ref)
+}
+
+// ResolveRefAt returns the hash of the commit that a ref pointed to at a
+// time: the last commit of its first-parent history committed at or before
+// then. It fails with ErrorRefNotFound if there is none.
+func (r *Repository) ResolveRefAt(ref string, at time.Time) (string, error) {
+	return r.backend().ResolveRefAt(r, ref, at)
+}
+
+// AddWorktree checks out a commit, detached, into a new directory, leaving
+// the checkout of the repository as is. The directory must not exist. It is
+// removed by RemoveWorktree.
+func (r *Repository) AddWorktree(dir, commit string) error {
+	return r.backend().AddWorktree(r, dir, commit)
+}
+
+// RemoveWorktree removes a directory added by AddWorktree.
+func (r *Repository) RemoveWorktree(dir string) error {
+	return r.backend().RemoveWorktree(r, dir)
+}
+
+// IsClean returns true if the Git repository is clean.
+func (r *Repository) IsClean() bool {
+	return r.backend().IsClean(r)
+}
+
+// CurrentBranch returns the name of the checked out branch, or the commit
+// hash if HEAD is detached.
+func (r *Repository) CurrentBranch() (string, error) {
+	return r.backend().CurrentBranch(r)
+}
+
+// Add stages the changes to the paths, including deletions. Paths are
+// relative to the repository directory.
+func (r *Repository) Add(paths ...string) error {
+	if err := r.backend().Add(r, paths...); err != nil {
+		return wrapOp(ErrorAddFailed, "", err)
+	}
+	return nil
+}
+
+// Commit commits the staged changes with a message. The author is given as
+// "Name <email>"; if it is empty, the configured user is the author. It
+// returns ErrorNothingToCommit if no changes are staged.
+func (r *Repository) Commit(author, message string) error {
+	staged, err := r.backend().HasStagedChanges(r)
+	if err != nil {
+		return wrapOp(ErrorCommitFailed, "", err)
+	}
+	if !staged {
+		return ErrorNothingToCommit
+	}
+	if err := r.backend().Commit(r, author, message); err != nil {
+		return wrapOp(ErrorCommitFailed, "", err)
+	}
+	return nil
+}
+
+// CreateBranch creates a branch at HEAD and checks it out. Uncommitted
+// changes are carried over to the branch.
+func (r *Repository) CreateBranch(name string) error {
+	if r.HasRef("refs/heads/" + name) {
+		return fmt.Errorf("%w: %s", ErrorBranchExists, name)
+	}
+	if err := r.backend().CreateBranch(r, name); err != nil {
+		return wrapOp(ErrorBranchFailed, name, err)
+	}
+	return nil
+}
+
+// DeleteBranch deletes a local branch, merged or not.
+func (r *Repository) DeleteBranch(name string) error {
+	if err := r.backend().DeleteBranch(r, name); err != nil {
+		return wrapOp(ErrorBranchFailed, name, err)
+	}
+	return nil
+}
+
+// Push pushes a branch to the origin remote and sets it as the upstream of
+// the branch.
+func (r *Repository) Push(branch string) error {
+	if err := r.backend().Push(r, branch); err != nil {
+		return wrapOp(ErrorPushFailed, branch, err)
+	}
+	return nil
+}
+
+// PullRebase fetches the main branch from the origin remote and rebases the
+// checked out branch onto it. Without a main branch, the upstream of the
+// checked out branch is pulled.
+func (r *Repository) PullRebase() error {
+	if err := r.backend().PullRebase(r); err != nil {
+		return wrapOp(ErrorPullFailed, "", err)
+	}
+	return nil
+}
+
+// remoteURL returns the URL of the origin remote, read from the
+// configuration of checkouts opened without one if credentials need it, or
+// "" if it cannot be read.
+func (r *Repository) remoteURL() string {
+	if r.URL != "" || r.Credentials == nil || r.Credentials.Token == "" {
+		return r.URL
+	}
+	bare := *r
+	bare.Credentials, bare.PreHook, bare.PostHook = nil, nil, nil
+	u, err := ExecBackend{}.RemoteURL(&bare)
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+// gitDir returns the path to the Git directory of the repository.
+func (r *Repository) gitDir() string {
+	if r.Bare {
+		return r.Dir
+	}
+	return filepath.Join(r.Dir, ".git")
+}
+
+// backend returns the backend of the repository.
+func (r *Repository) backend() Backend {
+	if r.Backend != nil {
+		return r.Backend
+	}
+	return DefaultBackend()
+}
+
+// Exec executes a command in the Git repository. A failed command returns a
+// *GitError. Exec always runs the git binary, whatever the backend.
+func (r *Repository) Exec(args ...string) error {
+	args = append([]string{"-C", r.Dir}, args...)
+	_, err := r.run(r.command(args...), false)
 	return err
 }
 
+// ExecContext is Exec with a context. The command is killed when the context
+// is done.
+func (r *Repository) ExecContext(ctx context.Context, args ...string) error {
+	return r.WithContext(ctx).Exec(args...)
+}
+
 // ExecOutput executes a command in the Git repository and returns its output.
+// A failed command returns a *GitError.
 func (r *Repository) ExecOutput(args ...string) (string, error) {
 	args = append([]string{"-C", r.Dir}, args...)
-	cmd := exec.Command("git", args...)
+	return r.run(r.command(args...), true)
+}
+
+// ExecOutputContext is ExecOutput with a context. The command is killed when
+// the context is done.
+func (r *Repository) ExecOutputContext(ctx context.Context, args ...string) (string, error) {
+	return r.WithContext(ctx).ExecOutput(args...)
+}
+
+// command returns a git command bound to the context of the repository. Git
+// is kept from prompting for credentials, so that it fails rather than wait
+// for input no one may be there to type.
+func (r *Repository) command(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(r.context(), "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+// run runs a Git command between the hooks, capturing its stderr into the
+// returned *GitError on failure. Stderr is still written to the writer set
+// by the pre hook, if any. The post hook is given the *GitError.
+func (r *Repository) run(cmd *exec.Cmd, output bool) (string, error) {
+	r.Credentials.apply(cmd, r.remoteURL())
 	if r.PreHook != nil {
 		if herr := r.PreHook(cmd); herr != nil {
 			return "", herr
 		}
 	}
-	out, err := cmd.Output()
+	stderr := &bytes.Buffer{}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	} else {
+		cmd.Stderr = stderr
+	}
+	out := &bytes.Buffer{}
+	if output {
+		if cmd.Stdout != nil {
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, out)
+		} else {
+			cmd.Stdout = out
+		}
+	}
+	err := runCommand(r.context(), cmd)
+	if ctxErr := r.context().Err(); err != nil && ctxErr != nil {
+		// The command was killed: report why rather than the signal.
+		err = fmt.Errorf("git %s: %w", r.Credentials.redact(redactURLs(strings.Join(cmd.Args[1:], " "))), ctxErr)
+	} else {
+		err = newGitError(cmd, redactURLs(r.Credentials.redact(stderr.String())), err)
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && r.Credentials != nil {
+			args := make([]string, len(gitErr.Args))
+			for i, arg := range gitErr.Args {
+				args[i] = r.Credentials.redact(arg)
+			}
+			gitErr.Args = args
+		}
+	}
 	if r.PostHook != nil {
 		if herr := r.PostHook(cmd, err); herr != nil {
 			return "", herr
 		}
 	}
-	return string(out), err
+	return out.String(), err
+}
+
+// runCommand runs a command, copying its output to the writers of the
+// command through pipes of its own. The pipes of exec.Cmd are waited for
+// until every process holding them exits, so a killed git would still wait
+// for its ssh or upload-pack child to give up. Once the context is done and
+// the command has exited, the output is no longer waited for.
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	copies := &sync.WaitGroup{}
+	readers := []*os.File{}
+	writers := []*os.File{}
+	closeAll := func(files []*os.File) {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+	pipe := func(w io.Writer) (io.Writer, error) {
+		if w == nil {
+			return nil, nil
+		}
+		if f, ok := w.(*os.File); ok {
+			return f, nil
+		}
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		readers, writers = append(readers, pr), append(writers, pw)
+		copies.Add(1)
+		go func() {
+			defer copies.Done()
+			_, _ = io.Copy(w, pr)
+		}()
+		return pw, nil
+	}
+	var err error
+	if cmd.Stdout, err = pipe(cmd.Stdout); err == nil {
+		cmd.Stderr, err = pipe(cmd.Stderr)
+	}
+	if err == nil {
+		err = cmd.Start()
+	}
+	// The command holds the write ends now, or failed to start.
+	closeAll(writers)
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if err != nil && ctx.Err() != nil {
+		// Give the output left in the pipes a moment to be copied, then
+		// unblock the copies by closing the read ends.
+		done := make(chan struct{})
+		go func() {
+			copies.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(100 * time.Millisecond):
+		}
+		closeAll(readers)
+	}
+	copies.Wait()
+	closeAll(readers)
+	return err
 }
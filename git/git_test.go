@@ -15,11 +15,16 @@
 package git
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/neuralnorthwest/tpology/git/mock_git"
@@ -183,6 +188,131 @@ func Test_GitRepository_Clone_Remote(t *testing.T) {
 	assert.Error(t, repo.Clone())
 }
 
+// Test_GitRepository_Clone_Options tests the Clone function with a shallow,
+// partial and sparse clone.
+func Test_GitRepository_Clone_Options(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	c.CloneOptions = CloneOptions{Depth: 1, Filter: "blob:none", SparsePaths: []string{"hosts", "schemas"}}
+	commitAll := func(t *testing.T, dir, message string) {
+		mustExecLog(t, "git", "-C", dir, "add", "--all")
+		mustExecLog(t, "git", "-C", dir, "commit", "-m", message)
+	}
+	origin := ""
+	repo, cleanup := newCustomLocalTestRepo(t, c, func(t *testing.T, dir string) {
+		origin = dir
+		mustExecLog(t, "git", "-C", dir, "config", "uploadpack.allowFilter", "true")
+		for _, kind := range []string{"hosts", "networks", "schemas"} {
+			assert.NoError(t, os.Mkdir(filepath.Join(dir, kind), 0755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, kind, "a.yaml"), []byte("name: a\n"), 0644))
+		}
+		commitAll(t, dir, "Add resources")
+	})
+	defer cleanup()
+	// Local paths are cloned without the transport, ignoring the depth.
+	repo.URL = "file://" + origin
+	assert.NoError(t, repo.Clone())
+	_, err := os.Stat(filepath.Join(repo.Dir, "hosts", "a.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(repo.Dir, "networks"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.True(t, repo.IsClean())
+	count, err := repo.ExecOutput("rev-list", "--count", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", strings.TrimSpace(count))
+	filter, err := repo.ExecOutput("config", "remote.origin.partialclonefilter")
+	assert.NoError(t, err)
+	assert.Equal(t, "blob:none", strings.TrimSpace(filter))
+
+	// Updates keep the sparse checkout.
+	assert.NoError(t, os.WriteFile(filepath.Join(origin, "hosts", "b.yaml"), []byte("name: b\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(origin, "networks", "b.yaml"), []byte("name: b\n"), 0644))
+	commitAll(t, origin, "Add more resources")
+	assert.NoError(t, repo.Update())
+	_, err = os.Stat(filepath.Join(repo.Dir, "hosts", "b.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(repo.Dir, "networks"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A failed sparse checkout leaves no clone behind.
+	bad := c.New(repo.URL, "main")
+	bad.Dir += "-bad"
+	bad.PreHook = func(cmd *exec.Cmd) error {
+		if strings.Contains(strings.Join(cmd.Args, " "), "sparse-checkout") {
+			return errors.New("sparse checkout failed")
+		}
+		return nil
+	}
+	assert.Error(t, bad.Clone())
+	assert.False(t, bad.IsCloned())
+}
+
+// Test_GitRepository_Clone_Concurrent tests that concurrent clones of a
+// repository leave a single complete clone.
+func Test_GitRepository_Clone_Concurrent(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	const n = 4
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			errs <- c.New(repo.URL, "main").Clone()
+		}()
+	}
+	cloned := 0
+	for i := 0; i < n; i++ {
+		if err := <-errs; err == nil {
+			cloned++
+		} else if !errors.Is(err, ErrorLocked) {
+			assert.Contains(t, err.Error(), "already cloned")
+		}
+	}
+	assert.Equal(t, 1, cloned)
+	assert.True(t, repo.IsCloned())
+	entries, err := os.ReadDir(filepath.Dir(repo.Dir))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, isCloneTemp(e.Name()), e.Name())
+	}
+}
+
+// Test_GitRepository_Clone_Partial tests that partial clones are not
+// cloned, and are replaced by a new clone.
+func Test_GitRepository_Clone_Partial(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	// A clone killed before it fetched anything, and a leftover temporary
+	// directory.
+	mustExecLog(t, "git", "init", "--quiet", repo.Dir)
+	temp := filepath.Join(filepath.Dir(repo.Dir), "."+filepath.Base(repo.Dir)+cloneTempInfix+"123")
+	assert.NoError(t, os.MkdirAll(filepath.Join(temp, ".git"), 0755))
+	assert.False(t, repo.IsCloned())
+	repos, err := c.Repositories()
+	assert.NoError(t, err)
+	assert.Len(t, repos, 1)
+	assert.NoError(t, repo.Clone())
+	assert.True(t, repo.IsCloned())
+	_, err = os.Stat(filepath.Join(repo.Dir, ".git", cloneMarker))
+	assert.NoError(t, err)
+	_, err = os.Stat(temp)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A clone in progress elsewhere holds the lock.
+	assert.NoError(t, repo.Remove())
+	unlock, err := repo.Lock()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, repo.Clone(), ErrorLocked)
+	unlock()
+	assert.NoError(t, repo.Clone())
+}
+
 // Test_GitRepository_CloneIfNotCloned tests the CloneIfNotCloned function.
 func Test_GitRepository_CloneIfNotCloned(t *testing.T) {
 	t.Parallel()
@@ -202,7 +332,7 @@ func Test_GitRepository_Clone_MkdirFails(t *testing.T) {
 	t.Parallel()
 	mockCtrl := gomock.NewController(t)
 	mockfs := mock_git.NewMockfs(mockCtrl)
-	mockfs.EXPECT().Stat(gomock.Any()).Return(nil, errors.New("stat failed"))
+	mockfs.EXPECT().Stat(gomock.Any()).Return(nil, errors.New("stat failed")).Times(2)
 	mockfs.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).Return(errors.New("mkdirall failed"))
 
 	c, _, cleanup := setupCache(t)
@@ -214,6 +344,37 @@ func Test_GitRepository_Clone_MkdirFails(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// Test_GitRepository_Clone_MarkerFails tests the Clone function when the
+// completion marker cannot be written.
+func Test_GitRepository_Clone_MarkerFails(t *testing.T) {
+	t.Parallel()
+	mockCtrl := gomock.NewController(t)
+	mockfs := mock_git.NewMockfs(mockCtrl)
+	mockfs.EXPECT().Stat(gomock.Any()).DoAndReturn(os.Stat).AnyTimes()
+	mockfs.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).DoAndReturn(os.MkdirAll).AnyTimes()
+	mockfs.EXPECT().ReadDir(gomock.Any()).DoAndReturn(os.ReadDir).AnyTimes()
+	mockfs.EXPECT().Open(gomock.Any()).DoAndReturn(os.Open).AnyTimes()
+	mockfs.EXPECT().Remove(gomock.Any()).DoAndReturn(os.Remove).AnyTimes()
+	mockfs.EXPECT().MkdirTemp(gomock.Any(), gomock.Any()).DoAndReturn(os.MkdirTemp)
+	mockfs.EXPECT().RemoveAll(gomock.Any()).DoAndReturn(os.RemoveAll).AnyTimes()
+	// The lock is written, the marker is not.
+	mockfs.EXPECT().OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(os.OpenFile)
+	mockfs.EXPECT().OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("disk full"))
+
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	c.fs = mockfs
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.EqualError(t, repo.Clone(), "disk full")
+	assert.False(t, repo.IsCloned())
+	entries, err := os.ReadDir(filepath.Dir(repo.Dir))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, isCloneTemp(e.Name()), e.Name())
+	}
+}
+
 // Test_GitRepository_Exec tests the Exec function.
 func Test_GitRepository_Exec(t *testing.T) {
 	t.Parallel()
@@ -227,6 +388,101 @@ func Test_GitRepository_Exec(t *testing.T) {
 	assert.Error(t, repo.Exec("non-existent-command"))
 }
 
+// Test_GitRepository_CloneContext tests that a clone stopped by its context
+// returns at once and leaves nothing behind.
+func Test_GitRepository_CloneContext(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	repo.Backend = ExecBackend{}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The remote hangs, and its process outlives the killed git.
+	start := time.Now()
+	err := repo.CloneContext(ctx, "--no-local", "--upload-pack", "sleep 5; git-upload-pack")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 4*time.Second)
+	assert.False(t, repo.IsCloned())
+	entries, err := os.ReadDir(filepath.Dir(repo.Dir))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, isCloneTemp(e.Name()), e.Name())
+	}
+	assert.NoError(t, repo.CloneContext(context.Background()))
+	assert.True(t, repo.IsCloned())
+}
+
+// Test_GitRepository_CloneContext_Redacted tests that the error of a clone
+// stopped by its context does not show the password of the URL.
+func Test_GitRepository_CloneContext_Redacted(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	// The remote accepts connections and never answers.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	url := fmt.Sprintf("https://jane:hunter2@%s/org/repo.git", l.Addr())
+	repo := c.New(url, "main")
+	repo.Backend = ExecBackend{}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = repo.CloneContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotContains(t, err.Error(), "hunter2")
+	assert.Contains(t, err.Error(), RedactURL(url))
+}
+
+// Test_GitRepository_ExecContext tests running commands with a context.
+func Test_GitRepository_ExecContext(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := repo.ExecOutputContext(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "main\n", out)
+	assert.NoError(t, repo.ExecContext(ctx, "status"))
+	cancel()
+	assert.ErrorIs(t, repo.ExecContext(ctx, "status"), context.Canceled)
+	_, err = repo.ExecOutputContext(ctx, "status")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, repo.CheckoutContext(ctx, "main"), context.Canceled)
+	// The repository itself is not bound to the context.
+	assert.NoError(t, repo.Exec("status"))
+}
+
+// Test_GitRepository_NoTerminalPrompt tests that git is kept from prompting
+// for credentials.
+func Test_GitRepository_NoTerminalPrompt(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	var env []string
+	repo.PreHook = func(cmd *exec.Cmd) error {
+		env = cmd.Env
+		return nil
+	}
+	assert.NoError(t, repo.Clone())
+	assert.Contains(t, env, "GIT_TERMINAL_PROMPT=0")
+}
+
 // Test_GitRepository_Remove tests the Remove function.
 func Test_GitRepository_Remove(t *testing.T) {
 	t.Parallel()
@@ -278,6 +534,243 @@ func Test_GitRepository_Checkout(t *testing.T) {
 	assert.NoError(t, repo.Checkout("test"))
 }
 
+// Test_GitRepository_HasRef tests the HasRef function.
+func Test_GitRepository_HasRef(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	assert.True(t, repo.HasRef("refs/heads/main"))
+	assert.True(t, repo.HasRef("refs/remotes/origin/main"))
+	assert.False(t, repo.HasRef("refs/heads/nonexistent"))
+}
+
+// Test_GitRepository_Update tests the Update function.
+func Test_GitRepository_Update(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	// Add a commit to the origin
+	_, err := os.Create(filepath.Join(repo.URL, "test.txt"))
+	assert.NoError(t, err)
+	mustExecLog(t, "git", "-C", repo.URL, "add", "test.txt")
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "-m", "test")
+	_, err = os.Stat(filepath.Join(repo.Dir, "test.txt"))
+	assert.Error(t, err)
+	assert.NoError(t, repo.Update())
+	_, err = os.Stat(filepath.Join(repo.Dir, "test.txt"))
+	assert.NoError(t, err)
+}
+
+// Test_GitRepository_IsUpToDate tests the IsUpToDate function.
+func Test_GitRepository_IsUpToDate(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	assert.True(t, repo.IsUpToDate())
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "--allow-empty", "-m", "Second commit")
+	assert.True(t, repo.IsUpToDate(), "not fetched yet")
+	assert.NoError(t, repo.Fetch())
+	assert.False(t, repo.IsUpToDate())
+	assert.NoError(t, repo.Update())
+	assert.True(t, repo.IsUpToDate())
+
+	mustExecLog(t, "git", "-C", repo.URL, "tag", "v1.0.0", "main~1")
+	repo.MainBranch = "v1.0.0"
+	assert.NoError(t, repo.Fetch())
+	assert.False(t, repo.IsUpToDate())
+	assert.NoError(t, repo.Update())
+	assert.True(t, repo.IsUpToDate())
+	repo.MainBranch = ""
+	assert.False(t, repo.IsUpToDate())
+}
+
+// Test_GitRepository_Update_Tag tests the Update function when the main
+// branch is a tag.
+func Test_GitRepository_Update_Tag(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	mustExecLog(t, "git", "-C", repo.URL, "tag", "v1.0.0")
+	repo.MainBranch = "v1.0.0"
+	assert.NoError(t, repo.Update())
+	assert.True(t, repo.HasRef("refs/tags/v1.0.0"))
+}
+
+// Test_GitRepository_ResolveRef tests the ResolveRef and HEAD functions.
+func Test_GitRepository_ResolveRef(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "--allow-empty", "-m", "Second commit")
+	mustExecLog(t, "git", "-C", repo.URL, "tag", "-a", "-m", "Release", "v1.0.0", "main~1")
+	assert.NoError(t, repo.Clone())
+	head, err := repo.HEAD()
+	assert.NoError(t, err)
+	assert.Len(t, head, 40)
+	hash, err := repo.ResolveRef("main")
+	assert.NoError(t, err)
+	assert.Equal(t, head, hash)
+	first, err := repo.ResolveRef("main~1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, head, first)
+	hash, err = repo.ResolveRef("v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, first, hash, "annotated tags resolve to their commit")
+	hash, err = repo.ResolveRef(first[:7])
+	assert.NoError(t, err)
+	assert.Equal(t, first, hash)
+	for _, ref := range []string{"nonexistent", "--all"} {
+		_, err = repo.ResolveRef(ref)
+		assert.ErrorIs(t, err, ErrorRefNotFound, ref)
+	}
+}
+
+// Test_GitRepository_Clone_Commit tests cloning and updating a repository
+// pinned to a commit hash.
+func Test_GitRepository_Clone_Commit(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	first, err := Open(repo.URL).HEAD()
+	assert.NoError(t, err)
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "--allow-empty", "-m", "Second commit")
+	repo.MainBranch = first[:10]
+	repo.CloneOptions.Depth = 1
+	assert.NoError(t, repo.Clone())
+	head, err := repo.HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, first, head)
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, first, branch, "HEAD is detached")
+
+	// Moving the pin checks out the new commit.
+	second, err := Open(repo.URL).HEAD()
+	assert.NoError(t, err)
+	repo.MainBranch = second
+	assert.NoError(t, repo.Update())
+	head, err = repo.HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, second, head)
+
+	repo.MainBranch = strings.Repeat("0", 40)
+	assert.ErrorIs(t, repo.Update(), ErrorRefNotFound)
+	assert.NoError(t, repo.Remove())
+	assert.ErrorIs(t, repo.Clone(), ErrorRefNotFound)
+	assert.False(t, repo.IsCloned())
+}
+
+// commitAt makes an empty commit in a repository with a committer date.
+func commitAt(t *testing.T, dir, message string, when time.Time) {
+	t.Helper()
+	cmd := exec.Command("git", "-C", dir, "commit", "--allow-empty", "-m", message)
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+when.Format(time.RFC3339))
+	output, err := cmd.CombinedOutput()
+	t.Log(string(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test_GitRepository_ResolveRefAt tests the ResolveRefAt function.
+func Test_GitRepository_ResolveRefAt(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	jan := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo, cleanup := newCustomLocalTestRepo(t, c, func(t *testing.T, dir string) {
+		commitAt(t, dir, "January", jan)
+		commitAt(t, dir, "February", feb)
+	})
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	head, err := repo.HEAD()
+	assert.NoError(t, err)
+	first, err := repo.ResolveRef("main~1")
+	assert.NoError(t, err)
+	hash, err := repo.ResolveRefAt("main", feb.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, head, hash)
+	hash, err = repo.ResolveRefAt("main", feb.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, first, hash)
+	_, err = repo.ResolveRefAt("main", jan.Add(-time.Hour))
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+	_, err = repo.ResolveRefAt("nonexistent", feb)
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+}
+
+// Test_GitRepository_AddWorktree tests the AddWorktree and RemoveWorktree
+// functions.
+func Test_GitRepository_AddWorktree(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	first, err := repo.HEAD()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.URL, "test.txt"), []byte("test\n"), 0644))
+	mustExecLog(t, "git", "-C", repo.URL, "add", "test.txt")
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "-m", "test")
+	assert.NoError(t, repo.Update())
+
+	dir := filepath.Join(t.TempDir(), "worktree")
+	assert.NoError(t, repo.AddWorktree(dir, first))
+	_, err = os.Stat(filepath.Join(dir, "test.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	head, err := Open(dir).HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, first, head)
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "main", branch)
+	_, err = os.Stat(filepath.Join(repo.Dir, "test.txt"))
+	assert.NoError(t, err)
+	assert.NoError(t, repo.RemoveWorktree(dir))
+	_, err = os.Stat(dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A worktree removed behind the back of git is pruned.
+	assert.NoError(t, repo.AddWorktree(dir, "main"))
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.NoError(t, repo.RemoveWorktree(dir))
+	out, err := repo.ExecOutput("worktree", "list", "--porcelain")
+	assert.NoError(t, err)
+	assert.NotContains(t, out, dir)
+	assert.ErrorIs(t, repo.AddWorktree(dir, "nonexistent"), ErrorRefNotFound)
+}
+
+// Test_GitRepository_Update_NoRemote tests the Update function when the
+// origin remote is gone.
+func Test_GitRepository_Update_NoRemote(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	assert.NoError(t, repo.Clone())
+	cleanup()
+	assert.Error(t, repo.Update())
+}
+
 // Test_GitRepository_IsClean tests the IsClean function.
 func Test_GitRepository_IsClean(t *testing.T) {
 	t.Parallel()
@@ -294,6 +787,154 @@ func Test_GitRepository_IsClean(t *testing.T) {
 	assert.False(t, repo.IsClean())
 }
 
+// setUser configures the committer of a clone, as test machines may have no
+// global Git identity.
+func setUser(t *testing.T, repo *Repository) {
+	t.Helper()
+	assert.NoError(t, repo.Exec("config", "user.name", "Clone User"))
+	assert.NoError(t, repo.Exec("config", "user.email", "clone@user"))
+}
+
+// Test_Open tests the Open function.
+func Test_Open(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	repo := Open(origin.URL)
+	assert.True(t, repo.IsCloned())
+	assert.True(t, repo.HasRef("refs/heads/main"))
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "main", branch)
+}
+
+// Test_GitRepository_CurrentBranch_Detached tests the CurrentBranch function
+// when HEAD is detached.
+func Test_GitRepository_CurrentBranch_Detached(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	head, err := repo.ExecOutput("rev-parse", "HEAD")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Exec("checkout", "--detach"))
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(head), branch)
+}
+
+// Test_GitRepository_Commit tests the Add and Commit functions.
+func Test_GitRepository_Commit(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	setUser(t, repo)
+	assert.ErrorIs(t, repo.Commit("", "empty"), ErrorNothingToCommit)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "test.txt"), []byte("test\n"), 0644))
+	assert.NoError(t, repo.Add("test.txt"))
+	assert.NoError(t, repo.Commit("Jane Doe <jane@example.com>", "Add test.txt"))
+	assert.True(t, repo.IsClean())
+	out, err := repo.ExecOutput("log", "-1", "--format=%an <%ae>|%cn|%s")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe <jane@example.com>|Clone User|Add test.txt\n", out)
+	// Deletions are staged too.
+	assert.NoError(t, os.Remove(filepath.Join(repo.Dir, "test.txt")))
+	assert.NoError(t, repo.Add("test.txt"))
+	assert.NoError(t, repo.Commit("", "Remove test.txt"))
+	out, err = repo.ExecOutput("log", "-1", "--format=%an")
+	assert.NoError(t, err)
+	assert.Equal(t, "Clone User\n", out)
+	assert.ErrorIs(t, repo.Add("missing.txt"), ErrorAddFailed)
+}
+
+// Test_GitRepository_Commit_BadAuthor tests the Commit function with an
+// author that is not "Name <email>".
+func Test_GitRepository_Commit_BadAuthor(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	setUser(t, repo)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "test.txt"), []byte("test\n"), 0644))
+	assert.NoError(t, repo.Add("test.txt"))
+	assert.ErrorIs(t, repo.Commit("nobody", "Add test.txt"), ErrorCommitFailed)
+}
+
+// Test_GitRepository_CreateBranch tests the CreateBranch and DeleteBranch
+// functions.
+func Test_GitRepository_CreateBranch(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	assert.NoError(t, repo.CreateBranch("topic"))
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "topic", branch)
+	assert.ErrorIs(t, repo.CreateBranch("topic"), ErrorBranchExists)
+	assert.ErrorIs(t, repo.CreateBranch("bad..name"), ErrorBranchFailed)
+	assert.NoError(t, repo.Checkout("main"))
+	assert.NoError(t, repo.DeleteBranch("topic"))
+	assert.False(t, repo.HasRef("refs/heads/topic"))
+	assert.ErrorIs(t, repo.DeleteBranch("topic"), ErrorBranchFailed)
+}
+
+// Test_GitRepository_Push tests the Push function.
+func Test_GitRepository_Push(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	assert.NoError(t, repo.Clone())
+	setUser(t, repo)
+	assert.NoError(t, repo.CreateBranch("topic"))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "test.txt"), []byte("test\n"), 0644))
+	assert.NoError(t, repo.Add("test.txt"))
+	assert.NoError(t, repo.Commit("", "Add test.txt"))
+	assert.NoError(t, repo.Push("topic"))
+	assert.True(t, Open(repo.URL).HasRef("refs/heads/topic"))
+	assert.True(t, repo.HasRef("refs/remotes/origin/topic"))
+	cleanup()
+	assert.ErrorIs(t, repo.Push("topic"), ErrorPushFailed)
+}
+
+// Test_GitRepository_PullRebase tests the PullRebase function.
+func Test_GitRepository_PullRebase(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	assert.NoError(t, repo.Clone())
+	setUser(t, repo)
+	// Commit on both sides
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.URL, "origin.txt"), []byte("origin\n"), 0644))
+	mustExecLog(t, "git", "-C", repo.URL, "add", "origin.txt")
+	mustExecLog(t, "git", "-C", repo.URL, "commit", "-m", "origin")
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "local.txt"), []byte("local\n"), 0644))
+	assert.NoError(t, repo.Add("local.txt"))
+	assert.NoError(t, repo.Commit("", "local"))
+	assert.NoError(t, repo.PullRebase())
+	out, err := repo.ExecOutput("log", "--format=%s")
+	assert.NoError(t, err)
+	assert.Equal(t, "local\norigin\nInitial commit\n", out)
+	// Without a main branch, the upstream is pulled.
+	repo.MainBranch = ""
+	assert.NoError(t, repo.PullRebase())
+	cleanup()
+	assert.ErrorIs(t, repo.PullRebase(), ErrorPullFailed)
+}
+
 // Test_GitRepository_Lock tests the Lock function.
 func Test_GitRepository_Lock(t *testing.T) {
 	t.Parallel()
@@ -333,9 +974,19 @@ func Test_GitRepository_LockFile_Write_Failed(t *testing.T) {
 	t.Parallel()
 	mockCtrl := gomock.NewController(t)
 	mockfs := mock_git.NewMockfs(mockCtrl)
-	mockfs.EXPECT().Stat(gomock.Any()).DoAndReturn(os.Stat)
-	mockfs.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).DoAndReturn(os.MkdirAll)
+	mockfs.EXPECT().Stat(gomock.Any()).DoAndReturn(os.Stat).AnyTimes()
+	mockfs.EXPECT().MkdirAll(gomock.Any(), gomock.Any()).DoAndReturn(os.MkdirAll).AnyTimes()
+	mockfs.EXPECT().ReadDir(gomock.Any()).DoAndReturn(os.ReadDir).AnyTimes()
+	mockfs.EXPECT().Open(gomock.Any()).DoAndReturn(os.Open).AnyTimes()
+	mockfs.EXPECT().MkdirTemp(gomock.Any(), gomock.Any()).DoAndReturn(os.MkdirTemp)
+	mockfs.EXPECT().Rename(gomock.Any(), gomock.Any()).DoAndReturn(os.Rename)
+	mockfs.EXPECT().RemoveAll(gomock.Any()).DoAndReturn(os.RemoveAll).AnyTimes()
+	mockfs.EXPECT().Chtimes(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(os.Chtimes).AnyTimes()
+	// The lock taken by the clone and its completion and fetch markers are
+	// written, the next lock is not.
+	mockfs.EXPECT().OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(os.OpenFile).Times(3)
 	mockfs.EXPECT().OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil) // return a nil file
+	mockfs.EXPECT().Remove(gomock.Any()).DoAndReturn(os.Remove).Times(2)
 	c, _, cleanup := setupCache(t)
 	defer cleanup()
 	c.fs = mockfs
@@ -0,0 +1,847 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// errDiverged is the error returned when a branch cannot be fast-forwarded.
+var errDiverged = errors.New("not possible to fast-forward, the branches have diverged")
+
+// GoBackend is the Backend running Git operations in process with go-git, so
+// that no git binary is needed. Local remotes are served in process too. The
+// hooks of the repository are not called, clone arguments and sparse clones
+// are not supported, partial clone filters are ignored, Clean keeps ignored
+// files and PullRebase only fast-forwards.
+type GoBackend struct{}
+
+// serveLocalOnce guards installing the in-process transport of local
+// remotes.
+var serveLocalOnce sync.Once
+
+// serveLocalRemotes serves local remotes in process instead of running
+// git-upload-pack and git-receive-pack.
+func serveLocalRemotes() {
+	serveLocalOnce.Do(func() {
+		client.InstallProtocol("file", localTransport{server.NewClient(localLoader{})})
+	})
+}
+
+// localTransport is the in-process transport of local remotes.
+type localTransport struct {
+	transport.Transport
+}
+
+// NewUploadPackSession implements transport.Transport.
+func (t localTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	sto, err := localLoader{}.Load(ep)
+	if err != nil {
+		return nil, err
+	}
+	session, err := t.Transport.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &localUploadPackSession{session, sto}, nil
+}
+
+// localUploadPackSession is an upload pack session of a local remote. The
+// go-git server fails on commits the client has but the remote has not, such
+// as unpushed commits, so those are dropped from the request.
+type localUploadPackSession struct {
+	transport.UploadPackSession
+	// storer is the storage of the remote.
+	storer storer.Storer
+}
+
+// UploadPack implements transport.UploadPackSession.
+func (s *localUploadPackSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	haves := []plumbing.Hash{}
+	for _, h := range req.Haves {
+		if s.storer.HasEncodedObject(h) == nil {
+			haves = append(haves, h)
+		}
+	}
+	req.Haves = haves
+	return s.UploadPackSession.UploadPack(ctx, req)
+}
+
+// localLoader loads the storage of local repositories, bare or not.
+type localLoader struct{}
+
+// Load implements server.Loader.
+func (localLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	for _, dir := range []string{filepath.Join(ep.Path, ".git"), ep.Path} {
+		if _, err := os.Stat(filepath.Join(dir, "HEAD")); err == nil {
+			return filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), nil
+		}
+	}
+	return nil, transport.ErrRepositoryNotFound
+}
+
+// goError classifies an error of go-git into the sentinel errors of the
+// package.
+func goError(err error) error {
+	if err == nil {
+		return nil
+	}
+	cause := err
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		cause = unexpected.Err
+	}
+	var permanent *plumbing.PermanentError
+	if errors.As(err, &permanent) {
+		cause = permanent.Err
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(cause, context.Canceled),
+		errors.Is(cause, context.DeadlineExceeded):
+		// A deadline is a net.Error too, but the remote was not at fault.
+		return err
+	case errors.Is(cause, transport.ErrAuthenticationRequired),
+		errors.Is(cause, transport.ErrAuthorizationFailed),
+		errors.Is(cause, transport.ErrInvalidAuthMethod):
+		return wrapOp(ErrorAuthFailed, "", err)
+	case errors.Is(cause, gogit.ErrRepositoryNotExists),
+		errors.Is(cause, transport.ErrRepositoryNotFound):
+		return wrapOp(ErrorNotRepository, "", err)
+	case errors.As(cause, &netErr):
+		return wrapOp(ErrorNetworkUnreachable, "", err)
+	case errors.Is(cause, plumbing.ErrReferenceNotFound),
+		errors.Is(cause, gogit.ErrBranchNotFound),
+		errors.Is(cause, gogit.NoMatchingRefSpecError{}):
+		return wrapOp(ErrorRefNotFound, "", err)
+	case errors.Is(cause, gogit.ErrUnstagedChanges),
+		errors.Is(cause, gogit.ErrWorktreeNotClean):
+		return wrapOp(ErrorMergeConflict, "", err)
+	}
+	return err
+}
+
+// open opens the repository and its working tree.
+func (GoBackend) open(r *Repository) (*gogit.Repository, *gogit.Worktree, error) {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return nil, nil, goError(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, goError(err)
+	}
+	return repo, w, nil
+}
+
+// Clone implements Backend.
+func (GoBackend) Clone(r *Repository, args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: clone arguments %v", ErrorUnsupported, args)
+	}
+	if len(r.CloneOptions.SparsePaths) > 0 {
+		return fmt.Errorf("%w: sparse checkout", ErrorUnsupported)
+	}
+	serveLocalRemotes()
+	auth, err := r.Credentials.goAuth(r.URL)
+	if err != nil {
+		return err
+	}
+	opts := &gogit.CloneOptions{URL: r.URL, Depth: r.CloneOptions.Depth, Auth: auth}
+	if r.MainBranch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(r.MainBranch)
+	}
+	_, err = gogit.PlainCloneContext(r.context(), r.Dir, r.Bare, opts)
+	if err != nil && r.MainBranch != "" && (errors.Is(err, gogit.NoMatchingRefSpecError{}) || errors.Is(err, plumbing.ErrReferenceNotFound)) {
+		// The main branch may be a tag, as with git clone -b.
+		opts.ReferenceName = plumbing.NewTagReferenceName(r.MainBranch)
+		_, err = gogit.PlainCloneContext(r.context(), r.Dir, r.Bare, opts)
+	}
+	return goError(err)
+}
+
+// Clean implements Backend. Ignored files are kept.
+func (b GoBackend) Clean(r *Repository) error {
+	_, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	return goError(w.Clean(&gogit.CleanOptions{Dir: true}))
+}
+
+// Checkout implements Backend. go-git cannot stop a checkout, so only a
+// context already done prevents it.
+func (b GoBackend) Checkout(r *Repository, ref string) error {
+	if err := r.context().Err(); err != nil {
+		return err
+	}
+	repo, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	opts := &gogit.CheckoutOptions{}
+	branch := plumbing.NewBranchReferenceName(ref)
+	remote := plumbing.NewRemoteReferenceName("origin", ref)
+	switch {
+	case b.HasRef(r, branch.String()):
+		opts.Branch = branch
+	case b.HasRef(r, remote.String()):
+		target, err := repo.Reference(remote, true)
+		if err != nil {
+			return goError(err)
+		}
+		opts.Branch, opts.Hash, opts.Create = branch, target.Hash(), true
+		if err := repo.CreateBranch(&config.Branch{Name: ref, Remote: "origin", Merge: branch}); err != nil {
+			return goError(err)
+		}
+	default:
+		hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+		if err != nil {
+			return wrapOp(ErrorRefNotFound, ref, err)
+		}
+		opts.Hash = *hash
+	}
+	return goError(w.Checkout(opts))
+}
+
+// Fetch implements Backend.
+func (b GoBackend) Fetch(r *Repository) error {
+	serveLocalRemotes()
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return goError(err)
+	}
+	auth, err := b.auth(r, repo)
+	if err != nil {
+		return err
+	}
+	opts := &gogit.FetchOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs: []config.RefSpec{
+			"+refs/heads/*:refs/remotes/origin/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+		Force: true,
+	}
+	if r.CloneOptions.Depth > 0 {
+		// Fetching all tags would fetch their whole history.
+		opts.RefSpecs, opts.Tags = opts.RefSpecs[:1], gogit.TagFollowing
+	}
+	err = repo.FetchContext(r.context(), opts)
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return goError(err)
+	}
+	return b.prune(r, repo, auth)
+}
+
+// auth returns the go-git authentication of the origin remote.
+func (GoBackend) auth(r *Repository, repo *gogit.Repository) (transport.AuthMethod, error) {
+	url := r.URL
+	if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+		url = remote.Config().URLs[0]
+	}
+	return r.Credentials.goAuth(url)
+}
+
+// prune deletes the remote-tracking branches of the origin remote whose
+// branch is gone.
+func (GoBackend) prune(r *Repository, repo *gogit.Repository, auth transport.AuthMethod) error {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return goError(err)
+	}
+	advertised, err := remote.ListContext(r.context(), &gogit.ListOptions{Auth: auth})
+	if err != nil {
+		return goError(err)
+	}
+	live := map[string]bool{}
+	for _, ref := range advertised {
+		if ref.Name().IsBranch() {
+			live[ref.Name().Short()] = true
+		}
+	}
+	refs, err := repo.References()
+	if err != nil {
+		return goError(err)
+	}
+	stale := []plumbing.ReferenceName{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if short := strings.TrimPrefix(name, "refs/remotes/origin/"); short != name && short != "HEAD" && !live[short] {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return goError(err)
+	}
+	for _, name := range stale {
+		if err := repo.Storer.RemoveReference(name); err != nil {
+			return goError(err)
+		}
+	}
+	return nil
+}
+
+// FastForward implements Backend.
+func (b GoBackend) FastForward(r *Repository, ref string) error {
+	repo, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	if ref == "" {
+		if ref, err = b.upstream(repo); err != nil {
+			return err
+		}
+	}
+	target, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return wrapOp(ErrorRefNotFound, ref, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return goError(err)
+	}
+	if head.Hash() == *target {
+		return nil
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return goError(err)
+	}
+	targetCommit, err := repo.CommitObject(*target)
+	if err != nil {
+		return goError(err)
+	}
+	// The common fast-forward is checked first, as it only walks the new
+	// commits, which a shallow clone has.
+	if behind, err := headCommit.IsAncestor(targetCommit); err != nil {
+		return goError(err)
+	} else if behind {
+		return goError(w.Reset(&gogit.ResetOptions{Commit: *target, Mode: gogit.MergeReset}))
+	}
+	if ahead, err := targetCommit.IsAncestor(headCommit); err != nil || ahead {
+		return goError(err)
+	}
+	return fmt.Errorf("%s: %w", ref, errDiverged)
+}
+
+// upstream returns the remote-tracking ref of the upstream of the checked out
+// branch.
+func (GoBackend) upstream(repo *gogit.Repository) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", goError(err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", goError(err)
+	}
+	branch, ok := cfg.Branches[head.Name().Short()]
+	if !head.Name().IsBranch() || !ok || branch.Remote == "" || branch.Merge == "" {
+		return "", fmt.Errorf("%w: no upstream for %s", ErrorRefNotFound, head.Name().Short())
+	}
+	return plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short()).String(), nil
+}
+
+// HasRef implements Backend.
+func (GoBackend) HasRef(r *Repository, ref string) bool {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return false
+	}
+	_, err = repo.Reference(plumbing.ReferenceName(ref), true)
+	return err == nil
+}
+
+// IsClean implements Backend.
+func (b GoBackend) IsClean(r *Repository) bool {
+	_, w, err := b.open(r)
+	if err != nil {
+		return false
+	}
+	status, err := w.Status()
+	return err == nil && status.IsClean()
+}
+
+// CurrentBranch implements Backend.
+func (b GoBackend) CurrentBranch(r *Repository) (string, error) {
+	repo, _, err := b.open(r)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", goError(err)
+	}
+	if head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+	return head.Hash().String(), nil
+}
+
+// Head implements Backend.
+func (b GoBackend) Head(r *Repository) (string, error) {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return "", goError(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", goError(err)
+	}
+	return head.Hash().String(), nil
+}
+
+// ResolveRef implements Backend.
+func (GoBackend) ResolveRef(r *Repository, ref string) (string, error) {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return "", goError(err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", wrapOp(ErrorRefNotFound, ref, err)
+	}
+	return hash.String(), nil
+}
+
+// ResolveRefAt implements Backend.
+func (GoBackend) ResolveRefAt(r *Repository, ref string, at time.Time) (string, error) {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return "", goError(err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", wrapOp(ErrorRefNotFound, ref, err)
+	}
+	c, err := repo.CommitObject(*hash)
+	for err == nil {
+		if !c.Committer.When.After(at) {
+			return c.Hash.String(), nil
+		}
+		if c.NumParents() == 0 {
+			break
+		}
+		c, err = c.Parent(0)
+	}
+	if err != nil {
+		return "", goError(err)
+	}
+	return "", fmt.Errorf("%w: no commit of %s at or before %s", ErrorRefNotFound, ref, at.Format(time.RFC3339))
+}
+
+// AddWorktree implements Backend. go-git has no linked worktrees, so the
+// files of the commit are written to the directory.
+func (GoBackend) AddWorktree(r *Repository, dir, commit string) error {
+	if len(r.CloneOptions.SparsePaths) > 0 {
+		return fmt.Errorf("%w: sparse checkout", ErrorUnsupported)
+	}
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return goError(err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+	if err != nil {
+		return wrapOp(ErrorRefNotFound, commit, err)
+	}
+	c, err := repo.CommitObject(*hash)
+	if err != nil {
+		return goError(err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return goError(err)
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		return writeTreeFile(dir, f)
+	}); err != nil {
+		_ = os.RemoveAll(dir)
+		return goError(err)
+	}
+	return nil
+}
+
+// writeTreeFile writes a file of a tree under a directory.
+func writeTreeFile(dir string, f *object.File) error {
+	path := filepath.Join(dir, filepath.FromSlash(f.Name))
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+		return fmt.Errorf("invalid path in tree: %s", f.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, path)
+	}
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+	rd, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, rd)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RemoveWorktree implements Backend.
+func (GoBackend) RemoveWorktree(r *Repository, dir string) error {
+	return r.fs.RemoveAll(dir)
+}
+
+// RemoteURL implements Backend.
+func (GoBackend) RemoteURL(r *Repository) (string, error) {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return "", goError(err)
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", goError(err)
+	}
+	if urls := remote.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("origin remote has no URL: %s", r.Dir)
+}
+
+// Add implements Backend.
+func (b GoBackend) Add(r *Repository, paths ...string) error {
+	_, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := os.Lstat(filepath.Join(r.Dir, path)); errors.Is(err, os.ErrNotExist) {
+			_, err = w.Remove(path)
+		} else {
+			_, err = w.Add(path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, goError(err))
+		}
+	}
+	return nil
+}
+
+// HasStagedChanges implements Backend.
+func (b GoBackend) HasStagedChanges(r *Repository) (bool, error) {
+	_, w, err := b.open(r)
+	if err != nil {
+		return false, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return false, goError(err)
+	}
+	for _, s := range status {
+		if s.Staging != gogit.Unmodified && s.Staging != gogit.Untracked {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// authorPattern matches "Name <email>".
+var authorPattern = regexp.MustCompile(`^\s*([^<>]*?)\s*<([^<>]*)>\s*$`)
+
+// Commit implements Backend. Without a configured user, the author is the
+// committer too.
+func (b GoBackend) Commit(r *Repository, author, message string) error {
+	repo, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return goError(err)
+	}
+	now := time.Now()
+	committer := &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: now}
+	signature := committer
+	if author != "" {
+		m := authorPattern.FindStringSubmatch(author)
+		if m == nil || m[1] == "" {
+			return fmt.Errorf("invalid author %q, expected \"Name <email>\"", author)
+		}
+		signature = &object.Signature{Name: m[1], Email: m[2], When: now}
+		if committer.Name == "" {
+			committer = signature
+		}
+	}
+	if signature.Name == "" {
+		return fmt.Errorf("no author: set user.name and user.email in the Git configuration")
+	}
+	_, err = w.Commit(message, &gogit.CommitOptions{Author: signature, Committer: committer})
+	return goError(err)
+}
+
+// CreateBranch implements Backend.
+func (b GoBackend) CreateBranch(r *Repository, name string) error {
+	_, w, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(name)
+	if !validBranchName(name) {
+		return fmt.Errorf("invalid branch name: %q", name)
+	}
+	return goError(w.Checkout(&gogit.CheckoutOptions{Branch: branch, Create: true, Keep: true}))
+}
+
+// validBranchName returns true if git accepts the branch name. See
+// git-check-ref-format.
+func validBranchName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, "/") ||
+		strings.ContainsAny(name, " ~^:?*[\\\x7f") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	for _, c := range name {
+		if c < ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteBranch implements Backend.
+func (b GoBackend) DeleteBranch(r *Repository, name string) error {
+	repo, _, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(name)
+	if _, err := repo.Reference(branch, false); err != nil {
+		return goError(err)
+	}
+	if err := repo.Storer.RemoveReference(branch); err != nil {
+		return goError(err)
+	}
+	if err := repo.DeleteBranch(name); err != nil && !errors.Is(err, gogit.ErrBranchNotFound) {
+		return goError(err)
+	}
+	return nil
+}
+
+// Push implements Backend.
+func (b GoBackend) Push(r *Repository, branch string) error {
+	serveLocalRemotes()
+	repo, _, err := b.open(r)
+	if err != nil {
+		return err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	ref, err := repo.Reference(name, true)
+	if err != nil {
+		return goError(err)
+	}
+	auth, err := b.auth(r, repo)
+	if err != nil {
+		return err
+	}
+	err = repo.PushContext(r.context(), &gogit.PushOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec(name + ":" + name)},
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return goError(err)
+	}
+	tracking := plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", branch), ref.Hash())
+	if err := repo.Storer.SetReference(tracking); err != nil {
+		return goError(err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return goError(err)
+	}
+	cfg.Branches[branch] = &config.Branch{Name: branch, Remote: "origin", Merge: name}
+	return goError(repo.SetConfig(cfg))
+}
+
+// PullRebase implements Backend. Only fast-forwards are supported; rebasing
+// local commits returns ErrorUnsupported.
+func (b GoBackend) PullRebase(r *Repository) error {
+	if err := b.Fetch(r); err != nil {
+		return err
+	}
+	ref := ""
+	if r.MainBranch != "" {
+		ref = plumbing.NewRemoteReferenceName("origin", r.MainBranch).String()
+	}
+	err := b.FastForward(r, ref)
+	if errors.Is(err, errDiverged) {
+		return fmt.Errorf("%w: rebasing local commits", ErrorUnsupported)
+	}
+	return err
+}
+
+// Verify implements Backend. Every object reachable from the refs is read,
+// which checks its compression and that it exists. The walk stops at the
+// shallow commits of a shallow clone, whose parents are not fetched.
+func (GoBackend) Verify(r *Repository) error {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return goError(err)
+	}
+	refs, err := repo.References()
+	if err != nil {
+		return goError(err)
+	}
+	pending := []plumbing.Hash{}
+	if err := refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			pending = append(pending, ref.Hash())
+		}
+		return nil
+	}); err != nil {
+		return goError(err)
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return goError(err)
+	}
+	isShallow := map[plumbing.Hash]bool{}
+	for _, h := range shallow {
+		isShallow[h] = true
+	}
+	seen := map[plumbing.Hash]bool{}
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		next, err := verifyObject(repo.Storer, h)
+		if err != nil {
+			return fmt.Errorf("object %s: %w", h, err)
+		}
+		if isShallow[h] && len(next) > 0 {
+			// Only the tree of a shallow commit is fetched.
+			next = next[:1]
+		}
+		pending = append(pending, next...)
+	}
+	return nil
+}
+
+// verifyObject reads an object and returns the objects it refers to.
+func verifyObject(s storer.EncodedObjectStorer, h plumbing.Hash) ([]plumbing.Hash, error) {
+	obj, err := s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, err
+	}
+	switch obj.Type() {
+	case plumbing.CommitObject:
+		c, err := object.DecodeCommit(s, obj)
+		if err != nil {
+			return nil, err
+		}
+		return append([]plumbing.Hash{c.TreeHash}, c.ParentHashes...), nil
+	case plumbing.TreeObject:
+		t, err := object.DecodeTree(s, obj)
+		if err != nil {
+			return nil, err
+		}
+		next := []plumbing.Hash{}
+		for _, e := range t.Entries {
+			// Submodule commits live in other repositories.
+			if e.Mode != filemode.Submodule {
+				next = append(next, e.Hash)
+			}
+		}
+		return next, nil
+	case plumbing.TagObject:
+		t, err := object.DecodeTag(s, obj)
+		if err != nil {
+			return nil, err
+		}
+		return []plumbing.Hash{t.Target}, nil
+	}
+	rd, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	_, err = io.Copy(io.Discard, rd)
+	return nil, err
+}
+
+// GC implements Backend. Unreachable loose objects are removed once they are
+// two weeks old, as with git gc, and the reachable objects are packed.
+func (GoBackend) GC(r *Repository) error {
+	repo, err := gogit.PlainOpen(r.Dir)
+	if err != nil {
+		return goError(err)
+	}
+	if err := repo.Prune(gogit.PruneOptions{
+		OnlyObjectsOlderThan: time.Now().Add(-14 * 24 * time.Hour),
+		Handler:              repo.DeleteObject,
+	}); err != nil {
+		return goError(err)
+	}
+	return goError(repo.RepackObjects(&gogit.RepackConfig{}))
+}
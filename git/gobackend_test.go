@@ -0,0 +1,358 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+// goSignature is the signature of the commits made by the tests.
+var goSignature = &object.Signature{Name: "Test User", Email: "test@user", When: time.Unix(1672531200, 0)}
+
+// goCommit writes a file in a go-git working tree and commits it.
+func goCommit(t *testing.T, repo *gogit.Repository, name, content string) {
+	t.Helper()
+	w, err := repo.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(w.Filesystem.Root(), name), []byte(content), 0644))
+	_, err = w.Add(name)
+	assert.NoError(t, err)
+	_, err = w.Commit("Update "+name, &gogit.CommitOptions{Author: goSignature})
+	assert.NoError(t, err)
+}
+
+// newGoTestRepo creates, without the git binary, a working repository on the
+// main branch and a bare clone of it, and returns a repository of the cache
+// cloning the bare one with the go-git backend, along with the working
+// repository.
+func newGoTestRepo(t *testing.T, c *Cache) (*Repository, *gogit.Repository) {
+	t.Helper()
+	serveLocalRemotes()
+	seedDir := filepath.Join(t.TempDir(), "seed")
+	seed, err := gogit.PlainInit(seedDir, false)
+	assert.NoError(t, err)
+	assert.NoError(t, seed.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Main)))
+	goCommit(t, seed, "README", "seed\n")
+	bareDir := filepath.Join(t.TempDir(), "origin.git")
+	_, err = gogit.PlainClone(bareDir, true, &gogit.CloneOptions{URL: seedDir})
+	assert.NoError(t, err)
+	// Later seed commits reach the bare origin through a push.
+	_, err = seed.CreateRemote(&config.RemoteConfig{Name: "bare", URLs: []string{bareDir}})
+	assert.NoError(t, err)
+	repo := c.New(bareDir, "main")
+	repo.Backend = GoBackend{}
+	return repo, seed
+}
+
+// goPush pushes the main branch of the working repository to the bare origin.
+func goPush(t *testing.T, seed *gogit.Repository) {
+	t.Helper()
+	assert.NoError(t, seed.Push(&gogit.PushOptions{
+		RemoteName: "bare",
+		RefSpecs:   []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"},
+	}))
+}
+
+// Test_GoBackend_NoGitBinary tests the go-git backend through a whole change
+// workflow with no git binary on the PATH.
+func Test_GoBackend_NoGitBinary(t *testing.T) {
+	t.Setenv("PATH", "")
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+
+	assert.NoError(t, repo.Clone())
+	assert.True(t, repo.HasRef("refs/heads/main"))
+	assert.True(t, repo.HasRef("refs/remotes/origin/main"))
+	assert.True(t, repo.IsClean())
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	// Update fast-forwards to the origin.
+	goCommit(t, seed, "hosts.yaml", "name: db-01\n")
+	goPush(t, seed)
+	assert.NoError(t, repo.Update())
+	_, err = os.Stat(filepath.Join(repo.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+
+	// Commit a change on a topic branch and push it.
+	assert.NoError(t, repo.CreateBranch("topic"))
+	assert.ErrorIs(t, repo.Commit("Jane Doe <jane@example.com>", "empty"), ErrorNothingToCommit)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "hosts.yaml"), []byte("name: db-02\n"), 0644))
+	assert.False(t, repo.IsClean())
+	assert.NoError(t, repo.Add("hosts.yaml"))
+	assert.NoError(t, repo.Commit("Jane Doe <jane@example.com>", "Rename db-01"))
+	assert.True(t, repo.IsClean())
+	assert.NoError(t, repo.Push("topic"))
+	assert.True(t, repo.HasRef("refs/remotes/origin/topic"))
+	origin, err := gogit.PlainOpen(repo.URL)
+	assert.NoError(t, err)
+	ref, err := origin.Reference(plumbing.NewBranchReferenceName("topic"), true)
+	assert.NoError(t, err)
+	commit, err := origin.CommitObject(ref.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", commit.Author.Name)
+	assert.Equal(t, "Rename db-01", commit.Message)
+
+	// Back to the main branch, deleting the topic branch.
+	assert.NoError(t, repo.Checkout("main"))
+	data, err := os.ReadFile(filepath.Join(repo.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "name: db-01\n", string(data))
+	assert.NoError(t, repo.DeleteBranch("topic"))
+	assert.False(t, repo.HasRef("refs/heads/topic"))
+
+	// Deletions are staged too.
+	assert.NoError(t, os.Remove(filepath.Join(repo.Dir, "hosts.yaml")))
+	assert.NoError(t, repo.Add("hosts.yaml"))
+	assert.NoError(t, repo.Commit("Jane Doe <jane@example.com>", "Remove db-01"))
+	assert.True(t, repo.IsClean())
+
+	// Clean removes untracked files.
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "untracked"), nil, 0644))
+	assert.NoError(t, repo.Clean())
+	assert.True(t, repo.IsClean())
+}
+
+// Test_GoBackend_Checkout tests checking out remote branches, tags and
+// commits with the go-git backend.
+func Test_GoBackend_Checkout(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	head, err := seed.Head()
+	assert.NoError(t, err)
+	_, err = seed.CreateTag("v1.0.0", head.Hash(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, seed.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("other"), head.Hash())))
+	goPush(t, seed)
+
+	assert.NoError(t, repo.Clone())
+	assert.NoError(t, repo.Checkout("other"))
+	branch, err := repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, "other", branch)
+	assert.NoError(t, repo.Checkout("v1.0.0"))
+	branch, err = repo.CurrentBranch()
+	assert.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), branch)
+	assert.ErrorIs(t, repo.Checkout("nonexistent"), ErrorRefNotFound)
+
+	tagged := c.New(repo.URL, "v1.0.0")
+	tagged.Dir += "-tag"
+	tagged.Backend = GoBackend{}
+	assert.NoError(t, tagged.Clone())
+	assert.NoError(t, tagged.Update())
+	hash, err := tagged.ResolveRef("v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), hash)
+	_, err = tagged.ResolveRef("nonexistent")
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+
+	// A commit hash is checked out detached.
+	goCommit(t, seed, "README", "second\n")
+	goPush(t, seed)
+	pinned := c.New(repo.URL, head.Hash().String()[:10])
+	pinned.Dir += "-commit"
+	pinned.Backend = GoBackend{}
+	assert.NoError(t, pinned.Clone())
+	hash, err = pinned.HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), hash)
+	assert.NoError(t, pinned.Update())
+	hash, err = pinned.HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), hash)
+}
+
+// Test_GoBackend_Worktree tests checking out past commits with the go-git
+// backend.
+func Test_GoBackend_Worktree(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	first, err := seed.Head()
+	assert.NoError(t, err)
+	goCommit(t, seed, "README", "second\n")
+	goPush(t, seed)
+	assert.NoError(t, repo.Clone())
+
+	hash, err := repo.ResolveRefAt("main", goSignature.When)
+	assert.NoError(t, err)
+	head, err := repo.HEAD()
+	assert.NoError(t, err)
+	assert.Equal(t, head, hash)
+	_, err = repo.ResolveRefAt("main", goSignature.When.Add(-time.Hour))
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+
+	dir := filepath.Join(t.TempDir(), "worktree")
+	assert.NoError(t, repo.AddWorktree(dir, first.Hash().String()))
+	content, err := os.ReadFile(filepath.Join(dir, "README"))
+	assert.NoError(t, err)
+	assert.Equal(t, "seed\n", string(content))
+	content, err = os.ReadFile(filepath.Join(repo.Dir, "README"))
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(content))
+	assert.NoError(t, repo.RemoveWorktree(dir))
+	_, err = os.Stat(dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorIs(t, repo.AddWorktree(dir, "nonexistent"), ErrorRefNotFound)
+}
+
+// Test_GoBackend_Errors tests the errors of the go-git backend.
+func Test_GoBackend_Errors(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	assert.ErrorIs(t, repo.Clone("--depth", "1"), ErrorUnsupported)
+	repo.CloneOptions.SparsePaths = []string{"hosts"}
+	assert.ErrorIs(t, repo.Clone(), ErrorUnsupported)
+	repo.CloneOptions = CloneOptions{Filter: "blob:none"}
+	assert.NoError(t, repo.Clone())
+	assert.ErrorIs(t, repo.CreateBranch("bad..name"), ErrorBranchFailed)
+	assert.ErrorIs(t, repo.DeleteBranch("nonexistent"), ErrorRefNotFound)
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "README"), []byte("local\n"), 0644))
+	assert.NoError(t, repo.Add("README"))
+	assert.ErrorIs(t, repo.Commit("nobody", "bad"), ErrorCommitFailed)
+	assert.NoError(t, repo.Commit("Jane Doe <jane@example.com>", "local"))
+
+	// Diverged branches cannot be rebased in process.
+	goCommit(t, seed, "README", "origin\n")
+	goPush(t, seed)
+	err := repo.PullRebase()
+	assert.ErrorIs(t, err, ErrorPullFailed)
+	assert.ErrorIs(t, err, ErrorUnsupported)
+
+	missing := c.New(filepath.Join(t.TempDir(), "missing"), "main")
+	missing.Backend = GoBackend{}
+	assert.ErrorIs(t, missing.Clone(), ErrorNotRepository)
+	_, err = GoBackend{}.CurrentBranch(Open(t.TempDir()))
+	assert.ErrorIs(t, err, ErrorNotRepository)
+}
+
+// Test_GoBackend_PullRebase tests that the go-git backend fast-forwards on
+// pull.
+func Test_GoBackend_PullRebase(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	assert.NoError(t, repo.Clone())
+	goCommit(t, seed, "hosts.yaml", "name: db-01\n")
+	goPush(t, seed)
+	assert.NoError(t, repo.PullRebase())
+	_, err := os.Stat(filepath.Join(repo.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+	// Without a main branch, the upstream is pulled.
+	repo.MainBranch = ""
+	assert.NoError(t, repo.PullRebase())
+}
+
+// Test_DefaultBackend tests the DefaultBackend function.
+func Test_DefaultBackend(t *testing.T) {
+	t.Parallel()
+	assert.NotNil(t, DefaultBackend())
+	assert.Equal(t, DefaultBackend(), Open(t.TempDir()).backend())
+	repo := Open(t.TempDir())
+	repo.Backend = GoBackend{}
+	assert.Equal(t, GoBackend{}, repo.backend())
+	for name, want := range map[string]Backend{"": DefaultBackend(), "auto": DefaultBackend(), "exec": ExecBackend{}, "go": GoBackend{}} {
+		backend, err := ParseBackend(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, backend)
+	}
+	_, err := ParseBackend("svn")
+	assert.Error(t, err)
+}
+
+// Test_GoBackend_Cache tests the cache maintenance of the go-git backend.
+func Test_GoBackend_Cache(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	assert.NoError(t, repo.Clone())
+	head, err := seed.Head()
+	assert.NoError(t, err)
+	info, err := repo.Info()
+	assert.NoError(t, err)
+	assert.Equal(t, repo.URL, info.URL)
+	assert.Equal(t, head.Hash().String(), info.Head)
+	assert.Equal(t, "main", info.Ref)
+	assert.NoError(t, c.Verify(repo))
+	assert.NoError(t, repo.GC())
+	assert.NoError(t, c.Verify(repo))
+	assert.NoError(t, os.RemoveAll(filepath.Join(repo.Dir, ".git", "objects")))
+	assert.ErrorIs(t, repo.Verify(), ErrorVerifyFailed)
+}
+
+// Test_GoBackend_Verify_Shallow tests verifying a depth-1 clone, whose
+// parent commits are not fetched.
+func Test_GoBackend_Verify_Shallow(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	mustExecLog(t, "git", "-C", origin.URL, "commit", "--allow-empty", "-m", "Second commit")
+	// The go-git transport cannot serve shallow clones.
+	dir := filepath.Join(t.TempDir(), "shallow")
+	mustExecLog(t, "git", "clone", "--quiet", "--depth", "1", "file://"+origin.URL, dir)
+	shallow, err := os.ReadFile(filepath.Join(dir, ".git", "shallow"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, shallow)
+	repo := Open(dir)
+	repo.Backend = GoBackend{}
+	assert.NoError(t, repo.Verify())
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, ".git", "objects")))
+	assert.ErrorIs(t, repo.Verify(), ErrorVerifyFailed)
+}
+
+// Test_GoBackend_CloneContext tests that the go-git backend stops a clone
+// when its context is done.
+func Test_GoBackend_CloneContext(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, _ := newGoTestRepo(t, c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := repo.CloneContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, errors.Is(err, ErrorNetworkUnreachable))
+	assert.False(t, repo.IsCloned())
+	entries, err := os.ReadDir(filepath.Dir(repo.Dir))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, isCloneTemp(e.Name()), e.Name())
+	}
+	assert.NoError(t, repo.CloneContext(context.Background()))
+	assert.ErrorIs(t, repo.CheckoutContext(ctx, "main"), context.Canceled)
+}
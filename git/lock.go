@@ -0,0 +1,276 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockMode is the mode of a repository lock.
+type LockMode int
+
+const (
+	// LockExclusive is held by a single process, e.g. to update the
+	// repository.
+	LockExclusive LockMode = iota
+	// LockShared is held by any number of processes at once, e.g. to read
+	// the repository, but not along with an exclusive lock.
+	LockShared
+)
+
+const (
+	// lockExclusiveName is the name of the file of the exclusive holder.
+	lockExclusiveName = "exclusive"
+	// lockSharedPrefix is the prefix of the names of the files of the shared
+	// holders.
+	lockSharedPrefix = "shared-"
+	// lockPollInterval is the interval between attempts of LockWithTimeout.
+	lockPollInterval = 100 * time.Millisecond
+	// lockUnreadableAge is the age after which a holder file that cannot be
+	// parsed is stale. Younger files may still be being written.
+	lockUnreadableAge = 10 * time.Second
+)
+
+// lockSeq numbers the shared locks of this process.
+var lockSeq uint64
+
+// lockHolder is a process holding a lock.
+type lockHolder struct {
+	// name is the name of the holder file.
+	name string
+	// pid is the PID of the process.
+	pid int
+}
+
+// exclusive returns true if the holder has the exclusive lock.
+func (h lockHolder) exclusive() bool {
+	return h.name == lockExclusiveName
+}
+
+// lockPath returns the path to the lock directory.
+func (r *Repository) lockPath() string {
+	if r.LockPath != "" {
+		return r.LockPath
+	}
+	return r.Dir + ".lock"
+}
+
+// Lock locks the Git repository exclusively. It returns an unlock function
+// and an error.
+func (r *Repository) Lock() (func(), error) {
+	return r.TryLock(LockExclusive)
+}
+
+// TryLock locks the Git repository in a mode, failing with ErrorLocked if a
+// conflicting lock is held. Each holder writes its PID and process start time
+// to a file of the lock directory, so that the locks of processes that exited
+// without unlocking are detected as stale and removed. It returns an unlock
+// function and an error.
+func (r *Repository) TryLock(mode LockMode) (func(), error) {
+	dir := r.lockPath()
+	if err := r.fs.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := r.lockHolders(dir); err != nil {
+		return nil, err
+	}
+	name := lockExclusiveName
+	if mode == LockShared {
+		name = fmt.Sprintf("%s%d-%d", lockSharedPrefix, os.Getpid(), atomic.AddUint64(&lockSeq, 1))
+	}
+	path := filepath.Join(dir, name)
+	f, err := r.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, r.lockedError(r.readLockHolder(dir, name))
+	} else if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(f, "%d %s\n", os.Getpid(), processStartTime(os.Getpid()))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = r.fs.Remove(path)
+		return nil, err
+	}
+	// Holders taking conflicting locks at once both see each other here, and
+	// both back off.
+	holders, err := r.lockHolders(dir)
+	if err != nil {
+		_ = r.fs.Remove(path)
+		return nil, err
+	}
+	for _, h := range holders {
+		if h.name != name && (mode == LockExclusive || h.exclusive()) {
+			_ = r.fs.Remove(path)
+			return nil, r.lockedError(h)
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_ = r.fs.Remove(path)
+		})
+	}, nil
+}
+
+// LockWithTimeout locks the Git repository in a mode, waiting for
+// conflicting locks to be released until the context is done. It returns an
+// unlock function and an error.
+func (r *Repository) LockWithTimeout(ctx context.Context, mode LockMode) (func(), error) {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		unlock, err := r.TryLock(mode)
+		if !errors.Is(err, ErrorLocked) {
+			return unlock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (%v)", err, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// LockerPID returns the PID of the process that locked the Git repository,
+// preferring the exclusive holder.
+func (r *Repository) LockerPID() (int, error) {
+	holders, err := r.lockHolders(r.lockPath())
+	if err == nil && len(holders) > 0 {
+		for _, h := range holders {
+			if h.exclusive() {
+				return h.pid, nil
+			}
+		}
+		return holders[0].pid, nil
+	}
+	return 0, fmt.Errorf("repository not locked: %s", RedactURL(r.URL))
+}
+
+// lockedError returns the error of a lock held by another process.
+func (r *Repository) lockedError(h lockHolder) error {
+	return fmt.Errorf("%w by PID %d: %s", ErrorLocked, h.pid, RedactURL(r.URL))
+}
+
+// lockHolders returns the live holders of the lock, removing the stale ones.
+// A missing lock directory has no holders.
+func (r *Repository) lockHolders(dir string) ([]lockHolder, error) {
+	entries, err := r.fs.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	holders := []lockHolder{}
+	for _, e := range entries {
+		name := e.Name()
+		if name != lockExclusiveName && !strings.HasPrefix(name, lockSharedPrefix) {
+			continue
+		}
+		content, modTime, err := r.readLockFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			// Released meanwhile.
+			continue
+		} else if err != nil {
+			holders = append(holders, lockHolder{name: name})
+			continue
+		}
+		pid, start, ok := parseLockFile(content)
+		if ok && !lockStale(pid, start) {
+			holders = append(holders, lockHolder{name: name, pid: pid})
+			continue
+		}
+		if !ok && time.Since(modTime) < lockUnreadableAge {
+			holders = append(holders, lockHolder{name: name})
+			continue
+		}
+		// Remove the stale file, unless it was replaced by a new holder
+		// meanwhile.
+		if again, _, rerr := r.readLockFile(filepath.Join(dir, name)); rerr == nil && again == content {
+			_ = r.fs.Remove(filepath.Join(dir, name))
+		}
+	}
+	return holders, nil
+}
+
+// readLockHolder returns the holder of a lock file, with a zero PID if it
+// cannot be read.
+func (r *Repository) readLockHolder(dir, name string) lockHolder {
+	h := lockHolder{name: name}
+	if content, _, err := r.readLockFile(filepath.Join(dir, name)); err == nil {
+		h.pid, _, _ = parseLockFile(content)
+	}
+	return h
+}
+
+// readLockFile returns the content and modification time of a lock file.
+func (r *Repository) readLockFile(path string) (string, time.Time, error) {
+	f, err := r.fs.Open(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	b, err := io.ReadAll(f)
+	return string(b), info.ModTime(), err
+}
+
+// parseLockFile returns the PID and process start time written to a lock
+// file. The start time is empty if the platform does not provide it.
+func parseLockFile(content string) (int, string, bool) {
+	if !strings.HasSuffix(content, "\n") {
+		return 0, "", false
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, "", false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return 0, "", false
+	}
+	if len(fields) == 1 {
+		return pid, "", true
+	}
+	return pid, fields[1], true
+}
+
+// lockStale returns true if the process holding a lock is gone. A process
+// whose start time differs from the recorded one reuses the PID of the
+// holder.
+func lockStale(pid int, start string) bool {
+	if !processAlive(pid) {
+		return true
+	}
+	if start == "" {
+		return false
+	}
+	current := processStartTime(pid)
+	return current != "" && current != start
+}
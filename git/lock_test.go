@@ -0,0 +1,172 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLockTestRepo returns a repository whose directory does not exist, for
+// locking only.
+func newLockTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	return Open(filepath.Join(t.TempDir(), "repo"))
+}
+
+// writeHolder writes the file of a lock holder.
+func writeHolder(t *testing.T, r *Repository, name, content string) string {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(r.lockPath(), 0755))
+	path := filepath.Join(r.lockPath(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("git", "--version")
+	assert.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+// Test_GitRepository_TryLock_Modes tests that shared locks are held together
+// and exclude an exclusive lock.
+func Test_GitRepository_TryLock_Modes(t *testing.T) {
+	t.Parallel()
+	repo := newLockTestRepo(t)
+	unlock1, err := repo.TryLock(LockShared)
+	assert.NoError(t, err)
+	unlock2, err := repo.TryLock(LockShared)
+	assert.NoError(t, err)
+	_, err = repo.TryLock(LockExclusive)
+	assert.ErrorIs(t, err, ErrorLocked)
+	assert.Contains(t, err.Error(), fmt.Sprintf("PID %d", os.Getpid()))
+	pid, err := repo.LockerPID()
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	unlock1()
+	unlock1()
+	_, err = repo.TryLock(LockExclusive)
+	assert.ErrorIs(t, err, ErrorLocked)
+	unlock2()
+
+	unlock, err := repo.TryLock(LockExclusive)
+	assert.NoError(t, err)
+	_, err = repo.TryLock(LockShared)
+	assert.ErrorIs(t, err, ErrorLocked)
+	_, err = repo.TryLock(LockExclusive)
+	assert.ErrorIs(t, err, ErrorLocked)
+	unlock()
+	_, err = repo.LockerPID()
+	assert.Error(t, err)
+	entries, err := os.ReadDir(repo.lockPath())
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// Test_GitRepository_TryLock_Stale tests that the locks of processes that
+// are gone are removed.
+func Test_GitRepository_TryLock_Stale(t *testing.T) {
+	t.Parallel()
+	repo := newLockTestRepo(t)
+	dead := deadPID(t)
+	writeHolder(t, repo, lockExclusiveName, fmt.Sprintf("%d 1\n", dead))
+	writeHolder(t, repo, "shared-1-1", fmt.Sprintf("%d\n", dead))
+	_, err := repo.LockerPID()
+	assert.Error(t, err)
+	unlock, err := repo.TryLock(LockExclusive)
+	assert.NoError(t, err)
+	pid, err := repo.LockerPID()
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	unlock()
+
+	// A holder file being written is live for a while.
+	path := writeHolder(t, repo, lockExclusiveName, "")
+	_, err = repo.TryLock(LockShared)
+	assert.ErrorIs(t, err, ErrorLocked)
+	old := time.Now().Add(-time.Minute)
+	assert.NoError(t, os.Chtimes(path, old, old))
+	unlock, err = repo.TryLock(LockShared)
+	assert.NoError(t, err)
+	unlock()
+}
+
+// Test_GitRepository_TryLock_ReusedPID tests that the lock of a process whose
+// PID was reused is stale.
+func Test_GitRepository_TryLock_ReusedPID(t *testing.T) {
+	t.Parallel()
+	start := processStartTime(os.Getpid())
+	if start == "" {
+		t.Skip("process start time unknown on this platform")
+	}
+	repo := newLockTestRepo(t)
+	writeHolder(t, repo, lockExclusiveName, fmt.Sprintf("%d %s\n", os.Getpid(), start))
+	_, err := repo.TryLock(LockExclusive)
+	assert.ErrorIs(t, err, ErrorLocked)
+	writeHolder(t, repo, lockExclusiveName, fmt.Sprintf("%d %s0\n", os.Getpid(), start))
+	unlock, err := repo.TryLock(LockExclusive)
+	assert.NoError(t, err)
+	unlock()
+}
+
+// Test_GitRepository_LockWithTimeout tests the LockWithTimeout function.
+func Test_GitRepository_LockWithTimeout(t *testing.T) {
+	t.Parallel()
+	repo := newLockTestRepo(t)
+	unlock, err := repo.TryLock(LockExclusive)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPollInterval)
+	defer cancel()
+	_, err = repo.LockWithTimeout(ctx, LockShared)
+	assert.ErrorIs(t, err, ErrorLocked)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+
+	time.AfterFunc(3*lockPollInterval, unlock)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	unlock, err = repo.LockWithTimeout(ctx, LockExclusive)
+	assert.NoError(t, err)
+	unlock()
+}
+
+// Test_GitRepository_Lock_IsClean tests that a locked repository is clean.
+func Test_GitRepository_Lock_IsClean(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, cleanup := newLocalTestRepo(t, c)
+	defer cleanup()
+	assert.NoError(t, repo.Clone())
+	unlock, err := repo.Lock()
+	assert.NoError(t, err)
+	defer unlock()
+	assert.True(t, repo.IsClean())
+	repo.LockPath = filepath.Join(c.CachePath, "custom.lock")
+	unlock2, err := repo.Lock()
+	assert.NoError(t, err)
+	defer unlock2()
+	_, err = os.Stat(filepath.Join(repo.LockPath, lockExclusiveName))
+	assert.NoError(t, err)
+}
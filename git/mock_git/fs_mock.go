@@ -7,6 +7,7 @@ package mock_git
 import (
 	os "os"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,6 +35,20 @@ func (m *Mockfs) EXPECT() *MockfsMockRecorder {
 	return m.recorder
 }
 
+// Chtimes mocks base method.
+func (m *Mockfs) Chtimes(name string, atime, mtime time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Chtimes", name, atime, mtime)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Chtimes indicates an expected call of Chtimes.
+func (mr *MockfsMockRecorder) Chtimes(name, atime, mtime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Chtimes", reflect.TypeOf((*Mockfs)(nil).Chtimes), name, atime, mtime)
+}
+
 // MkdirAll mocks base method.
 func (m *Mockfs) MkdirAll(path string, perm os.FileMode) error {
 	m.ctrl.T.Helper()
@@ -93,6 +108,21 @@ func (mr *MockfsMockRecorder) OpenFile(name, flag, perm interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenFile", reflect.TypeOf((*Mockfs)(nil).OpenFile), name, flag, perm)
 }
 
+// ReadDir mocks base method.
+func (m *Mockfs) ReadDir(name string) ([]os.DirEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadDir", name)
+	ret0, _ := ret[0].([]os.DirEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadDir indicates an expected call of ReadDir.
+func (mr *MockfsMockRecorder) ReadDir(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadDir", reflect.TypeOf((*Mockfs)(nil).ReadDir), name)
+}
+
 // Remove mocks base method.
 func (m *Mockfs) Remove(name string) error {
 	m.ctrl.T.Helper()
@@ -121,6 +151,20 @@ func (mr *MockfsMockRecorder) RemoveAll(path interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*Mockfs)(nil).RemoveAll), path)
 }
 
+// Rename mocks base method.
+func (m *Mockfs) Rename(oldpath, newpath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rename", oldpath, newpath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rename indicates an expected call of Rename.
+func (mr *MockfsMockRecorder) Rename(oldpath, newpath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rename", reflect.TypeOf((*Mockfs)(nil).Rename), oldpath, newpath)
+}
+
 // Stat mocks base method.
 func (m *Mockfs) Stat(name string) (os.FileInfo, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,304 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// mirrorsDir is the directory of the cache holding the bare mirrors.
+	mirrorsDir = ".mirrors"
+	// worktreesDir is the directory of the cache holding the worktrees of
+	// the mirrors.
+	worktreesDir = ".worktrees"
+	// worktreeReady is the file of the lock directory of a worktree marking
+	// it as complete.
+	worktreeReady = "ready"
+)
+
+// worktreeName matches the directory names of the worktrees: the commit
+// hash, followed by a digest of the sparse paths for sparse worktrees.
+var worktreeName = regexp.MustCompile(`^[0-9a-f]{40}(-[0-9a-f]{8})?$`)
+
+// Worktree is a checkout of a commit of a repository of the cache. The
+// worktrees of a repository share the objects of its bare mirror, so that
+// refs are checked out side by side without cloning again. A worktree is
+// referenced by a shared lock while acquired, and the worktrees that are no
+// longer referenced are removed by Cache.PruneWorktrees. With SparsePaths in
+// the clone options of the cache, only those paths are checked out, in a
+// worktree of their own.
+type Worktree struct {
+	// Repository is the checkout of the worktree. Its MainBranch is the ref
+	// it was acquired for.
+	*Repository
+	// Commit is the hash of the checked out commit.
+	Commit string
+	// Mirror is the bare mirror of the repository.
+	Mirror *Repository
+	// release drops the reference to the worktree.
+	release func()
+}
+
+// Mirror returns the bare mirror of the repository at a URL. It is cloned
+// by Acquire on first use, and brought up to date with Fetch.
+func (c *Cache) Mirror(url string) *Repository {
+	return &Repository{
+		URL:          url,
+		Dir:          filepath.Join(c.CachePath, mirrorsDir, cleanURL(url)),
+		Bare:         true,
+		Backend:      c.Backend,
+		CloneOptions: CloneOptions{Depth: c.CloneOptions.Depth, Filter: c.CloneOptions.Filter},
+		Credentials:  c.Credentials,
+		fs:           c.fs,
+	}
+}
+
+// Acquire returns the worktree of the commit that a branch, tag or commit of
+// the repository at a URL points to, and references it until it is
+// released. The mirror is cloned on first use, but not fetched; call Fetch
+// on the mirror first to see new commits. Refs pointing to the same commit
+// share a worktree. Acquire waits for other processes cloning the mirror or
+// adding the worktree until the context is done, which also stops the clone
+// and the checkout.
+func (c *Cache) Acquire(ctx context.Context, url, ref string) (*Worktree, error) {
+	mirror := c.Mirror(url)
+	for !mirror.IsCloned() {
+		err := mirror.CloneContext(ctx)
+		if err == nil {
+			break
+		} else if !errors.Is(err, ErrorLocked) {
+			return nil, err
+		}
+		unlock, err := mirror.LockWithTimeout(ctx, LockShared)
+		if err != nil {
+			return nil, err
+		}
+		unlock()
+	}
+	// Keep the mirror from being fetched while the worktree is added.
+	unlockMirror, err := mirror.LockWithTimeout(ctx, LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockMirror()
+	commit, err := mirror.resolveBranch(ref)
+	if err != nil {
+		return nil, err
+	}
+	w := &Worktree{
+		Repository: &Repository{
+			URL:          url,
+			MainBranch:   ref,
+			Dir:          filepath.Join(c.CachePath, worktreesDir, cleanURL(url), worktreeDirName(commit, c.CloneOptions.SparsePaths)),
+			Backend:      c.Backend,
+			CloneOptions: c.CloneOptions,
+			Credentials:  c.Credentials,
+			fs:           c.fs,
+		},
+		Commit: commit,
+		Mirror: mirror,
+	}
+	for {
+		if !w.ready() {
+			if err := w.add(ctx); err != nil {
+				return nil, err
+			}
+		}
+		unlock, err := w.LockWithTimeout(ctx, LockShared)
+		if err != nil {
+			return nil, err
+		}
+		// The worktree may have been pruned before it was locked.
+		if w.ready() {
+			w.release = unlock
+			return w, nil
+		}
+		unlock()
+	}
+}
+
+// Release drops the reference to the worktree. The worktree is left in
+// place for later use.
+func (w *Worktree) Release() {
+	if w.release != nil {
+		w.release()
+	}
+}
+
+// worktreeDirName returns the directory name of the worktree of a commit
+// checking out sparse paths, or the whole commit if there are none.
+func worktreeDirName(commit string, sparsePaths []string) string {
+	if len(sparsePaths) == 0 {
+		return commit
+	}
+	paths := append([]string{}, sparsePaths...)
+	sort.Strings(paths)
+	sum := sha1.Sum([]byte(strings.Join(paths, "\n")))
+	return commit + "-" + hex.EncodeToString(sum[:4])
+}
+
+// resolveBranch returns the hash of the commit that a branch of the origin
+// remote, a tag or a commit points to.
+func (r *Repository) resolveBranch(ref string) (string, error) {
+	commit, err := r.ResolveRef("refs/remotes/origin/" + ref)
+	if errors.Is(err, ErrorRefNotFound) {
+		commit, err = r.ResolveRef(ref)
+	}
+	return commit, err
+}
+
+// ready returns true if the worktree is complete.
+func (w *Worktree) ready() bool {
+	_, err := w.fs.Stat(filepath.Join(w.lockPath(), worktreeReady))
+	return err == nil
+}
+
+// add adds the worktree while holding its exclusive lock, replacing what is
+// left of an incomplete one, unless another process added it meanwhile.
+func (w *Worktree) add(ctx context.Context) error {
+	unlock, err := w.LockWithTimeout(ctx, LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if w.ready() {
+		return nil
+	}
+	if _, err := w.fs.Stat(w.Dir); err == nil {
+		if err := w.Mirror.RemoveWorktree(w.Dir); err != nil {
+			return err
+		}
+	}
+	if err := w.fs.MkdirAll(filepath.Dir(w.Dir), 0755); err != nil {
+		return err
+	}
+	// The mirror checks out the sparse paths of the worktree.
+	mirror := *w.Mirror
+	mirror.CloneOptions.SparsePaths = w.CloneOptions.SparsePaths
+	if err := mirror.WithContext(ctx).AddWorktree(w.Dir, w.Commit); err != nil {
+		_ = w.Mirror.RemoveWorktree(w.Dir)
+		return err
+	}
+	return createFile(w.fs, filepath.Join(w.lockPath(), worktreeReady))
+}
+
+// PruneWorktrees removes the worktrees of the cache that are not
+// referenced, and returns their directories. Worktrees in use are kept. The
+// mirrors are left in place; Prune and Remove remove them.
+func (c *Cache) PruneWorktrees() ([]string, error) {
+	removed, _, err := c.pruneWorktrees("")
+	return removed, err
+}
+
+// pruneWorktrees removes the worktrees under a directory relative to the
+// worktrees directory that are not referenced. It returns their directories
+// and the number of worktrees kept.
+func (c *Cache) pruneWorktrees(dir string) ([]string, int, error) {
+	root := filepath.Join(c.CachePath, worktreesDir)
+	removed := []string{}
+	kept := 0
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := c.fs.ReadDir(filepath.Join(root, rel))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			name := filepath.Join(rel, e.Name())
+			if !worktreeName.MatchString(e.Name()) {
+				if filepath.Ext(name) != ".lock" {
+					if err := walk(name); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			mirror := &Repository{Dir: filepath.Join(c.CachePath, mirrorsDir, rel), Bare: true, Backend: c.Backend, fs: c.fs}
+			w := &Worktree{Repository: &Repository{Dir: filepath.Join(root, name), Backend: c.Backend, fs: c.fs}, Mirror: mirror}
+			if err := w.remove(); errors.Is(err, ErrorLocked) {
+				kept++
+				continue
+			} else if err != nil {
+				return err
+			}
+			removed = append(removed, w.Dir)
+		}
+		return nil
+	}
+	if err := walk(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return removed, kept, err
+	}
+	return removed, kept, nil
+}
+
+// removeMirror removes a bare mirror along with its worktrees and their lock
+// directories, while holding the exclusive lock of the mirror, which keeps
+// Acquire from adding worktrees meanwhile. It fails with ErrorLocked if the
+// mirror or one of its worktrees is in use.
+func (c *Cache) removeMirror(m *Repository) error {
+	rel, err := filepath.Rel(filepath.Join(c.CachePath, mirrorsDir), m.Dir)
+	if err != nil {
+		return err
+	}
+	unlock, err := m.TryLock(LockExclusive)
+	if err != nil {
+		return err
+	}
+	_, kept, err := c.pruneWorktrees(rel)
+	if err == nil && kept > 0 {
+		err = fmt.Errorf("%w: %d worktrees of %s in use", ErrorLocked, kept, m.Dir)
+	}
+	if err == nil {
+		err = c.fs.RemoveAll(filepath.Join(c.CachePath, worktreesDir, rel))
+	}
+	if err == nil {
+		err = m.Remove()
+	}
+	unlock()
+	// The lock directory is only removed if no one else is waiting on it.
+	_ = m.fs.Remove(m.lockPath())
+	return err
+}
+
+// remove removes the worktree and its lock directory while holding the
+// exclusive lock. It fails with ErrorLocked if the worktree is referenced.
+func (w *Worktree) remove() error {
+	unlock, err := w.TryLock(LockExclusive)
+	if err != nil {
+		return err
+	}
+	_ = w.fs.Remove(filepath.Join(w.lockPath(), worktreeReady))
+	err = w.Mirror.RemoveWorktree(w.Dir)
+	unlock()
+	// The lock directory is only removed if no one else is waiting on it.
+	_ = w.fs.Remove(w.lockPath())
+	if err != nil {
+		return fmt.Errorf("removing worktree %s: %w", w.Dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Cache_Acquire tests checking out two refs of a repository side by
+// side from its mirror.
+func Test_Cache_Acquire(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanupOrigin := newCustomLocalTestRepo(t, c, func(t *testing.T, dir string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "hosts.yaml"), []byte("name: db-01\n"), 0644))
+		mustExecLog(t, "git", "-C", dir, "add", "hosts.yaml")
+		mustExecLog(t, "git", "-C", dir, "commit", "-m", "Add db-01")
+		mustExecLog(t, "git", "-C", dir, "tag", "v1")
+		mustExecLog(t, "git", "-C", dir, "checkout", "-b", "next")
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "hosts.yaml"), []byte("name: db-02\n"), 0644))
+		mustExecLog(t, "git", "-C", dir, "commit", "-am", "Rename db-01")
+		mustExecLog(t, "git", "-C", dir, "checkout", "main")
+	})
+	defer cleanupOrigin()
+	ctx := context.Background()
+
+	main, err := c.Acquire(ctx, origin.URL, "main")
+	assert.NoError(t, err)
+	next, err := c.Acquire(ctx, origin.URL, "next")
+	assert.NoError(t, err)
+	assert.NotEqual(t, main.Dir, next.Dir)
+	assert.Equal(t, filepath.Join(c.CachePath, mirrorsDir, cleanURL(origin.URL)), main.Mirror.Dir)
+	data, err := os.ReadFile(filepath.Join(main.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "name: db-01\n", string(data))
+	data, err = os.ReadFile(filepath.Join(next.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "name: db-02\n", string(data))
+
+	// Refs pointing to the same commit share the worktree.
+	tag, err := c.Acquire(ctx, origin.URL, "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, main.Dir, tag.Dir)
+	assert.Equal(t, main.Commit, tag.Commit)
+
+	// The mirror is a cached repository, but the worktrees are not.
+	repos, err := c.Repositories()
+	assert.NoError(t, err)
+	if assert.Len(t, repos, 1) {
+		assert.Equal(t, main.Mirror.Dir, repos[0].Dir)
+		assert.True(t, repos[0].Bare)
+		assert.Equal(t, origin.URL, repos[0].URL)
+		assert.NoError(t, c.Verify(repos[0]))
+	}
+
+	// Unknown refs fail.
+	_, err = c.Acquire(ctx, origin.URL, "nope")
+	assert.ErrorIs(t, err, ErrorRefNotFound)
+
+	main.Release()
+	next.Release()
+	tag.Release()
+}
+
+// Test_Cache_Acquire_Sparse tests that sparse worktrees only check out their
+// paths, apart from the full worktree of the same commit.
+func Test_Cache_Acquire_Sparse(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanupOrigin := newCustomLocalTestRepo(t, c, func(t *testing.T, dir string) {
+		for _, d := range []string{"hosts", "networks"} {
+			assert.NoError(t, os.Mkdir(filepath.Join(dir, d), 0755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, d, "a.yaml"), []byte("name: a\n"), 0644))
+		}
+		mustExecLog(t, "git", "-C", dir, "add", ".")
+		mustExecLog(t, "git", "-C", dir, "commit", "-m", "Add hosts and networks")
+	})
+	defer cleanupOrigin()
+	ctx := context.Background()
+
+	sparse := *c
+	sparse.CloneOptions.SparsePaths = []string{"hosts"}
+	w, err := sparse.Acquire(ctx, origin.URL, "main")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Release()
+	_, err = os.Stat(filepath.Join(w.Dir, "hosts", "a.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(w.Dir, "networks"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.True(t, w.IsClean())
+
+	full, err := c.Acquire(ctx, origin.URL, "main")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer full.Release()
+	assert.Equal(t, w.Commit, full.Commit)
+	assert.NotEqual(t, w.Dir, full.Dir)
+	_, err = os.Stat(filepath.Join(full.Dir, "networks", "a.yaml"))
+	assert.NoError(t, err)
+
+	// Sparse worktrees are pruned like the others.
+	w.Release()
+	removed, err := c.PruneWorktrees()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{w.Dir}, removed)
+}
+
+// Test_Cache_PruneWorktrees tests that only the worktrees no longer referenced
+// are pruned.
+func Test_Cache_PruneWorktrees(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanupOrigin := newCustomLocalTestRepo(t, c, func(t *testing.T, dir string) {
+		mustExecLog(t, "git", "-C", dir, "branch", "next")
+		mustExecLog(t, "git", "-C", dir, "commit", "--allow-empty", "-m", "Second commit")
+	})
+	defer cleanupOrigin()
+	ctx := context.Background()
+
+	removed, err := c.PruneWorktrees()
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	main, err := c.Acquire(ctx, origin.URL, "main")
+	assert.NoError(t, err)
+	next, err := c.Acquire(ctx, origin.URL, "next")
+	assert.NoError(t, err)
+	next.Release()
+
+	removed, err = c.PruneWorktrees()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{next.Dir}, removed)
+	_, err = os.Stat(next.Dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(main.Dir)
+	assert.NoError(t, err)
+
+	// A pruned worktree is added again.
+	next, err = c.Acquire(ctx, origin.URL, "next")
+	assert.NoError(t, err)
+	_, err = os.Stat(next.Dir)
+	assert.NoError(t, err)
+	main.Release()
+	next.Release()
+
+	removed, err = c.PruneWorktrees()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{main.Dir, next.Dir}, removed)
+	mustExecLog(t, "git", "-C", main.Mirror.Dir, "worktree", "prune")
+}
+
+// Test_Cache_Remove_Mirror tests that Remove removes the mirror of a URL and
+// its worktrees, unless a worktree is in use.
+func Test_Cache_Remove_Mirror(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanupOrigin := newLocalTestRepo(t, c)
+	defer cleanupOrigin()
+	w, err := c.Acquire(context.Background(), origin.URL, "main")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, c.Remove(origin.URL), ErrorLocked)
+	_, err = os.Stat(w.Dir)
+	assert.NoError(t, err)
+	w.Release()
+
+	assert.NoError(t, c.Remove(origin.URL))
+	for _, dir := range []string{w.Mirror.Dir, w.Mirror.Dir + ".lock", filepath.Join(c.CachePath, worktreesDir, cleanURL(origin.URL))} {
+		_, err = os.Stat(dir)
+		assert.ErrorIs(t, err, os.ErrNotExist, dir)
+	}
+	assert.ErrorIs(t, c.Remove(origin.URL), ErrorNotCached)
+}
+
+// Test_Cache_Prune_Mirror tests that Prune removes the mirrors not fetched
+// recently whose worktrees are not in use.
+func Test_Cache_Prune_Mirror(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	origin, cleanupOrigin := newLocalTestRepo(t, c)
+	defer cleanupOrigin()
+	w, err := c.Acquire(context.Background(), origin.URL, "main")
+	assert.NoError(t, err)
+
+	removed, err := c.Prune(0)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+	w.Release()
+
+	removed, err = c.Prune(0)
+	assert.NoError(t, err)
+	if assert.Len(t, removed, 1) {
+		assert.Equal(t, w.Mirror.Dir, removed[0].Dir)
+	}
+	_, err = os.Stat(w.Dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	repos, err := c.Repositories()
+	assert.NoError(t, err)
+	assert.Empty(t, repos)
+}
+
+// Test_GoBackend_Acquire tests worktrees of a mirror with the go-git
+// backend.
+func Test_GoBackend_Acquire(t *testing.T) {
+	t.Parallel()
+	c, _, cleanup := setupCache(t)
+	defer cleanup()
+	repo, seed := newGoTestRepo(t, c)
+	c.Backend = GoBackend{}
+	ctx := context.Background()
+
+	first, err := c.Acquire(ctx, repo.URL, "main")
+	assert.NoError(t, err)
+	goCommit(t, seed, "hosts.yaml", "name: db-01\n")
+	goPush(t, seed)
+	assert.NoError(t, first.Mirror.Fetch())
+	second, err := c.Acquire(ctx, repo.URL, "main")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Dir, second.Dir)
+	_, err = os.Stat(filepath.Join(first.Dir, "hosts.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(second.Dir, "hosts.yaml"))
+	assert.NoError(t, err)
+
+	first.Release()
+	removed, err := c.PruneWorktrees()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first.Dir}, removed)
+	second.Release()
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"gopkg.in/yaml.v3"
+)
+
+// ColumnDir is the directory of the inventory holding the named column sets
+// of the resource kinds, one <kind>.yaml file per kind:
+//
+//	default: [name, owner, data.ip]
+//	network:
+//	  - name
+//	  - header: IP
+//	    path: data.ip
+const ColumnDir = "columns"
+
+// DefaultColumnSet is the name of the column set used when listing resources
+// of a kind without choosing columns.
+const DefaultColumnSet = "default"
+
+// Column is a table column showing the value at a path of each resource. See
+// resource.Resource.Field.
+type Column struct {
+	// Header is the column header.
+	Header string `yaml:"header"`
+	// Path is the dotted path of the value shown in the column.
+	Path string `yaml:"path"`
+}
+
+// ParseColumn parses a column from "<path>" or "<header>:<path>". A leading
+// "." or "$." on the path is ignored. The header defaults to the path.
+func ParseColumn(s string) (Column, error) {
+	c := Column{Path: strings.TrimSpace(s)}
+	if header, path, ok := strings.Cut(c.Path, ":"); ok {
+		c = Column{Header: strings.TrimSpace(header), Path: strings.TrimSpace(path)}
+	}
+	return c.normalize()
+}
+
+// ParseColumns parses a comma separated list of columns.
+func ParseColumns(s string) ([]Column, error) {
+	columns := []Column{}
+	for _, field := range strings.Split(s, ",") {
+		c, err := ParseColumn(field)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. A column is either
+// a string parsed by ParseColumn or a map with a header and a path.
+func (c *Column) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		parsed, err := ParseColumn(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		*c = parsed
+		return nil
+	}
+	type plain Column
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	normalized, err := c.normalize()
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*c = normalized
+	return nil
+}
+
+// normalize strips the JSONPath-like prefix of the path, defaults the header
+// and checks that the path starts with a resource field.
+func (c Column) normalize() (Column, error) {
+	c.Path = strings.TrimPrefix(strings.TrimPrefix(c.Path, "$"), ".")
+	if !resource.IsFieldPath(c.Path) {
+		return c, fmt.Errorf("invalid column path: %q", c.Path)
+	}
+	if c.Header == "" {
+		c.Header = c.Path
+	}
+	return c, nil
+}
+
+// ColumnSets are the named column sets of a kind.
+type ColumnSets map[string][]Column
+
+// LoadColumnDir loads the column sets of every kind from a directory holding
+// one <kind>.yaml file per kind. A missing directory yields no column sets.
+func LoadColumnDir(dir string) (map[string]ColumnSets, error) {
+	sets := make(map[string]ColumnSets)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sets, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if e := strings.ToLower(ext); e != ".yaml" && e != ".yml" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		kindSets := ColumnSets{}
+		if err := yaml.Unmarshal(data, &kindSets); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sets[strings.TrimSuffix(entry.Name(), ext)] = kindSets
+	}
+	return sets, nil
+}
+
+// ColumnSet returns the named column set of a kind.
+func (inv *Inventory) ColumnSet(kind, name string) ([]Column, bool) {
+	columns, ok := inv.Columns[kind][name]
+	return columns, ok
+}
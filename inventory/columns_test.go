@@ -0,0 +1,90 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_ParseColumns tests the ParseColumns function.
+func Test_ParseColumns(t *testing.T) {
+	t.Parallel()
+	columns, err := ParseColumns("name, owner,IP:data.ip,$.data.os.version,.labels.env")
+	assert.NoError(t, err)
+	assert.Equal(t, []Column{
+		{Header: "name", Path: "name"},
+		{Header: "owner", Path: "owner"},
+		{Header: "IP", Path: "data.ip"},
+		{Header: "data.os.version", Path: "data.os.version"},
+		{Header: "labels.env", Path: "labels.env"},
+	}, columns)
+	for _, s := range []string{"", "name,", "dta.ip", "IP:"} {
+		_, err := ParseColumns(s)
+		assert.Error(t, err, s)
+	}
+}
+
+// Test_LoadColumnDir tests the LoadColumnDir function.
+func Test_LoadColumnDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"host.yaml": `
+default: [name, owner, data.ip]
+network:
+  - name
+  - header: IP
+    path: data.ip
+`,
+		"README.md": "not a column set",
+	})
+	sets, err := LoadColumnDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, sets, 1)
+	assert.Len(t, sets["host"][DefaultColumnSet], 3)
+	assert.Equal(t, []Column{
+		{Header: "name", Path: "name"},
+		{Header: "IP", Path: "data.ip"},
+	}, sets["host"]["network"])
+
+	sets, err = LoadColumnDir(t.TempDir() + "/missing")
+	assert.NoError(t, err)
+	assert.Len(t, sets, 0)
+
+	writeFiles(t, dir, map[string]string{"service.yaml": "default: [dta.ip]\n"})
+	_, err = LoadColumnDir(dir)
+	assert.Error(t, err)
+}
+
+// Test_Inventory_Load_Columns tests that the inventory load function loads
+// the column sets and does not load them as resources.
+func Test_Inventory_Load_Columns(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"columns/host.yaml": "default: [name, data.ip]\n",
+		"hosts.yaml":        "name: db-01\nhost:\n  ip: 10.0.0.1\n",
+	})
+	inv, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Len(t, inv.Resources, 1)
+	columns, ok := inv.ColumnSet("host", DefaultColumnSet)
+	assert.True(t, ok)
+	assert.Len(t, columns, 2)
+	_, ok = inv.ColumnSet("host", "other")
+	assert.False(t, ok)
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/neuralnorthwest/tpology/schema"
+)
+
+// Error is an inventory error.
+type Error string
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	// ErrorDanglingRef is the error returned when a resource references a
+	// resource that does not exist.
+	ErrorDanglingRef = Error("dangling reference")
+	// ErrorDuplicateResource is the error returned when a resource is
+	// defined more than once.
+	ErrorDuplicateResource = Error("duplicate resource")
+)
+
+// Errors is a list of errors collected while loading the inventory.
+type Errors []error
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// append appends an error to the list, flattening errors that wrap a list of
+// errors.
+func (e Errors) append(err error) Errors {
+	if err == nil {
+		return e
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range multi.Unwrap() {
+			e = e.append(err)
+		}
+		return e
+	}
+	return append(e, err)
+}
+
+// orNil returns the list as an error, or nil if it is empty.
+func (e Errors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// FileErrors are the errors that occurred in a file.
+type FileErrors struct {
+	// Path is the path to the file, or an empty string for errors that did
+	// not occur in a file.
+	Path string
+	// Errors are the errors that occurred in the file.
+	Errors Errors
+}
+
+// ByFile groups the errors by the file they occurred in, in the order the
+// files first appear.
+func (e Errors) ByFile() []FileErrors {
+	groups := []FileErrors{}
+	index := map[string]int{}
+	for _, err := range e {
+		path := errorPosition(err).Path
+		i, ok := index[path]
+		if !ok {
+			i = len(groups)
+			index[path] = i
+			groups = append(groups, FileErrors{Path: path})
+		}
+		groups[i].Errors = append(groups[i].Errors, err)
+	}
+	return groups
+}
+
+// errorPosition returns the position an error occurred at, if any.
+func errorPosition(err error) resource.Position {
+	var loadErr *resource.LoadError
+	if errors.As(err, &loadErr) {
+		return loadErr.Position
+	}
+	var resErr *ResourceError
+	if errors.As(err, &resErr) {
+		return resErr.Position
+	}
+	var valErr *ValidationError
+	if errors.As(err, &valErr) {
+		return valErr.Position
+	}
+	return resource.Position{}
+}
+
+// ValidationError is a resource whose data does not conform to the schema of
+// its kind.
+type ValidationError struct {
+	resource.Position
+	// Kind is the kind of the resource.
+	Kind string
+	// Name is the name of the resource.
+	Name string
+	// Violation is the schema violation.
+	Violation *schema.Violation
+}
+
+// newValidationError returns a new validation error for a resource.
+func newValidationError(r *resource.Resource, v *schema.Violation) *ValidationError {
+	return &ValidationError{
+		Position:  r.Position(),
+		Kind:      r.Kind,
+		Name:      r.Name,
+		Violation: v,
+	}
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s/%s: %v", e.Position, e.Kind, e.Name, e.Violation)
+}
+
+// Unwrap returns the schema violation.
+func (e *ValidationError) Unwrap() error {
+	return e.Violation
+}
+
+// ResourceError is an error in a resource of the inventory.
+type ResourceError struct {
+	resource.Position
+	// Kind is the kind of the resource.
+	Kind string
+	// Name is the name of the resource.
+	Name string
+	// Err is the error.
+	Err error
+}
+
+// newResourceError returns a new error in a resource.
+func newResourceError(r *resource.Resource, err error) *ResourceError {
+	return &ResourceError{
+		Position: r.Position(),
+		Kind:     r.Kind,
+		Name:     r.Name,
+		Err:      err,
+	}
+}
+
+// Error implements the error interface.
+func (e *ResourceError) Error() string {
+	return fmt.Sprintf("%v: %s/%s: %v", e.Position, e.Kind, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
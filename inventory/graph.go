@@ -0,0 +1,342 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/neuralnorthwest/tpology/resource"
+)
+
+// graph is the dependency graph of the inventory. An edge from A to B means
+// that the data of A references B.
+type graph struct {
+	// deps maps resources to the resources they reference.
+	deps map[resource.Ref][]resource.Ref
+	// dependents maps resources to the resources referencing them.
+	dependents map[resource.Ref][]resource.Ref
+}
+
+// Get returns the resource identified by the reference.
+func (inv *Inventory) Get(ref resource.Ref) (*resource.Resource, bool) {
+	r, ok := inv.Resources[ref.Kind][ref.Name]
+	return r, ok
+}
+
+// ResolveRefs resolves the references in the data of every resource and
+// builds the dependency graph. It returns an Errors holding a ResourceError
+// for each malformed or dangling reference.
+func (inv *Inventory) ResolveRefs() error {
+	g := &graph{
+		deps:       make(map[resource.Ref][]resource.Ref),
+		dependents: make(map[resource.Ref][]resource.Ref),
+	}
+	errs := Errors{}
+	for _, r := range inv.sortedResources() {
+		refs, err := r.References()
+		if err != nil {
+			errs = append(errs, newResourceError(r, err))
+			continue
+		}
+		seen := map[resource.Ref]bool{}
+		for _, ref := range refs {
+			if _, ok := inv.Get(ref.Ref); !ok {
+				errs = append(errs, newResourceError(r, fmt.Errorf("%s: %w: %s", ref.Path, ErrorDanglingRef, ref.Ref)))
+				continue
+			}
+			if seen[ref.Ref] {
+				continue
+			}
+			seen[ref.Ref] = true
+			g.deps[r.Ref()] = append(g.deps[r.Ref()], ref.Ref)
+			g.dependents[ref.Ref] = append(g.dependents[ref.Ref], r.Ref())
+		}
+	}
+	for _, refs := range g.deps {
+		resource.SortRefs(refs)
+	}
+	for _, refs := range g.dependents {
+		resource.SortRefs(refs)
+	}
+	inv.graph = g
+	return errs.orNil()
+}
+
+// getGraph returns the dependency graph, resolving references if needed.
+// Malformed and dangling references are left out of the graph.
+func (inv *Inventory) getGraph() *graph {
+	if inv.graph == nil {
+		_ = inv.ResolveRefs()
+	}
+	return inv.graph
+}
+
+// Dependencies returns the resources referenced by the resource.
+func (inv *Inventory) Dependencies(ref resource.Ref) []*resource.Resource {
+	return inv.resolve(inv.getGraph().deps[ref])
+}
+
+// Dependents returns the resources referencing the resource.
+func (inv *Inventory) Dependents(ref resource.Ref) []*resource.Resource {
+	return inv.resolve(inv.getGraph().dependents[ref])
+}
+
+// TransitiveDependencies returns the resources the resource depends on,
+// directly or indirectly. The resource itself is included only if it is part
+// of a cycle.
+func (inv *Inventory) TransitiveDependencies(ref resource.Ref) []*resource.Resource {
+	return inv.resolve(closure(inv.getGraph().deps, ref))
+}
+
+// TransitiveDependents returns the resources depending on the resource,
+// directly or indirectly. The resource itself is included only if it is part
+// of a cycle.
+func (inv *Inventory) TransitiveDependents(ref resource.Ref) []*resource.Resource {
+	return inv.resolve(closure(inv.getGraph().dependents, ref))
+}
+
+// Cycles returns the dependency cycles of the inventory. Each cycle is the
+// sorted list of the resources that depend on each other, and the cycles are
+// sorted by their first resource.
+func (inv *Inventory) Cycles() [][]resource.Ref {
+	g := inv.getGraph()
+	nodes := make([]resource.Ref, 0, len(g.deps))
+	for ref := range g.deps {
+		nodes = append(nodes, ref)
+	}
+	resource.SortRefs(nodes)
+	cycles := [][]resource.Ref{}
+	for _, scc := range stronglyConnected(g.deps, nodes) {
+		if len(scc) == 1 && !contains(g.deps[scc[0]], scc[0]) {
+			continue
+		}
+		resource.SortRefs(scc)
+		cycles = append(cycles, scc)
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0].Less(cycles[j][0])
+	})
+	return cycles
+}
+
+// resolve returns the resources identified by the references.
+func (inv *Inventory) resolve(refs []resource.Ref) []*resource.Resource {
+	resources := make([]*resource.Resource, 0, len(refs))
+	for _, ref := range refs {
+		if r, ok := inv.Get(ref); ok {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// sortedResources returns every resource of the inventory sorted by the file
+// and document they were loaded from.
+func (inv *Inventory) sortedResources() []*resource.Resource {
+	resources := []*resource.Resource{}
+	for _, byName := range inv.Resources {
+		for _, r := range byName {
+			resources = append(resources, r)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].LoadedFrom() != resources[j].LoadedFrom() {
+			return resources[i].LoadedFrom() < resources[j].LoadedFrom()
+		}
+		if resources[i].Index() != resources[j].Index() {
+			return resources[i].Index() < resources[j].Index()
+		}
+		return resources[i].Ref().Less(resources[j].Ref())
+	})
+	return resources
+}
+
+// closure returns the sorted references reachable from ref.
+func closure(edges map[resource.Ref][]resource.Ref, ref resource.Ref) []resource.Ref {
+	seen := map[resource.Ref]bool{}
+	queue := append([]resource.Ref{}, edges[ref]...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		queue = append(queue, edges[next]...)
+	}
+	refs := make([]resource.Ref, 0, len(seen))
+	for r := range seen {
+		refs = append(refs, r)
+	}
+	resource.SortRefs(refs)
+	return refs
+}
+
+// stronglyConnected returns the strongly connected components of the graph
+// using Tarjan's algorithm.
+func stronglyConnected(edges map[resource.Ref][]resource.Ref, nodes []resource.Ref) [][]resource.Ref {
+	index := map[resource.Ref]int{}
+	lowlink := map[resource.Ref]int{}
+	onStack := map[resource.Ref]bool{}
+	stack := []resource.Ref{}
+	sccs := [][]resource.Ref{}
+	var visit func(v resource.Ref)
+	visit = func(v resource.Ref) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range edges[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+		if lowlink[v] == index[v] {
+			scc := []resource.Ref{}
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+	for _, v := range nodes {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return sccs
+}
+
+// contains returns true if the references contain ref.
+func contains(refs []resource.Ref, ref resource.Ref) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// SubgraphFilter selects part of the dependency graph.
+type SubgraphFilter struct {
+	// Kinds are the kinds of the selected resources. Empty selects all kinds.
+	Kinds []string
+	// Owners are the owners of the selected resources. Empty selects all
+	// owners.
+	Owners []string
+	// Root is the resource whose dependencies are selected. Nil selects the
+	// whole inventory.
+	Root *resource.Ref
+	// MaxDepth is the maximum distance from Root of the selected resources.
+	// Zero means no limit.
+	MaxDepth int
+}
+
+// Subgraph is a part of the dependency graph.
+type Subgraph struct {
+	// Nodes are the selected resources, sorted by kind and name.
+	Nodes []*resource.Resource
+	// Edges map the selected resources to the selected resources they
+	// reference.
+	Edges map[resource.Ref][]resource.Ref
+}
+
+// Subgraph returns the part of the dependency graph selected by the filter.
+// Only edges between selected resources are kept.
+func (inv *Inventory) Subgraph(filter SubgraphFilter) (*Subgraph, error) {
+	g := inv.getGraph()
+	var candidates []resource.Ref
+	if filter.Root != nil {
+		if _, ok := inv.Get(*filter.Root); !ok {
+			return nil, fmt.Errorf("resource not found: %s", filter.Root)
+		}
+		candidates = reachable(g.deps, *filter.Root, filter.MaxDepth)
+	} else {
+		for _, byName := range inv.Resources {
+			for _, r := range byName {
+				candidates = append(candidates, r.Ref())
+			}
+		}
+	}
+	resource.SortRefs(candidates)
+	sub := &Subgraph{
+		Nodes: []*resource.Resource{},
+		Edges: make(map[resource.Ref][]resource.Ref),
+	}
+	selected := map[resource.Ref]bool{}
+	for _, ref := range candidates {
+		r, ok := inv.Get(ref)
+		if !ok || !matchAny(filter.Kinds, r.Kind) || !matchAny(filter.Owners, r.Owner) {
+			continue
+		}
+		selected[ref] = true
+		sub.Nodes = append(sub.Nodes, r)
+	}
+	for ref := range selected {
+		edges := []resource.Ref{}
+		for _, dep := range g.deps[ref] {
+			if selected[dep] {
+				edges = append(edges, dep)
+			}
+		}
+		sub.Edges[ref] = edges
+	}
+	return sub, nil
+}
+
+// reachable returns the references reachable from ref, including ref itself,
+// within maxDepth edges. A maxDepth of zero means no limit.
+func reachable(edges map[resource.Ref][]resource.Ref, ref resource.Ref, maxDepth int) []resource.Ref {
+	depth := map[resource.Ref]int{ref: 0}
+	refs := []resource.Ref{ref}
+	for i := 0; i < len(refs); i++ {
+		next := refs[i]
+		if maxDepth > 0 && depth[next] >= maxDepth {
+			continue
+		}
+		for _, dep := range edges[next] {
+			if _, ok := depth[dep]; !ok {
+				depth[dep] = depth[next] + 1
+				refs = append(refs, dep)
+			}
+		}
+	}
+	return refs
+}
+
+// matchAny returns true if values is empty or contains value.
+func matchAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
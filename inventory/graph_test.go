@@ -0,0 +1,211 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// ref returns a reference to a resource.
+func ref(kind, name string) resource.Ref {
+	return resource.Ref{Kind: kind, Name: name}
+}
+
+// refData returns resource data referencing the given resources.
+func refData(refs ...string) interface{} {
+	data := map[string]interface{}{}
+	for i, r := range refs {
+		data[string(rune('a'+i))] = map[string]interface{}{"ref": r}
+	}
+	return data
+}
+
+// refsOf returns the references to the resources.
+func refsOf(resources []*resource.Resource) []resource.Ref {
+	refs := []resource.Ref{}
+	for _, r := range resources {
+		refs = append(refs, r.Ref())
+	}
+	return refs
+}
+
+// newTestGraph returns an inventory with the following dependencies:
+//
+//	service/api -> host/db-01, host/web-01
+//	host/web-01 -> host/db-01
+//	ring/a -> ring/b -> ring/c -> ring/a
+//	ring/self -> ring/self
+func newTestGraph() *Inventory {
+	inv := New()
+	add := func(kind, name string, refs ...string) {
+		inv.AddResource(&resource.Resource{Kind: kind, Name: name, Data: refData(refs...)})
+	}
+	add("service", "api", "host/db-01", "host/web-01", "host/db-01")
+	add("host", "web-01", "host/db-01")
+	add("host", "db-01")
+	add("ring", "a", "ring/b")
+	add("ring", "b", "ring/c")
+	add("ring", "c", "ring/a")
+	add("ring", "self", "ring/self")
+	return inv
+}
+
+// Test_Inventory_Get tests the Get function.
+func Test_Inventory_Get(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	r, ok := inv.Get(ref("host", "db-01"))
+	assert.True(t, ok)
+	assert.Equal(t, "db-01", r.Name)
+	_, ok = inv.Get(ref("host", "db-02"))
+	assert.False(t, ok)
+}
+
+// Test_Inventory_Dependencies tests the Dependencies and Dependents functions.
+func Test_Inventory_Dependencies(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	assert.NoError(t, inv.ResolveRefs())
+	assert.Equal(t, []resource.Ref{ref("host", "db-01"), ref("host", "web-01")}, refsOf(inv.Dependencies(ref("service", "api"))))
+	assert.Equal(t, []resource.Ref{}, refsOf(inv.Dependencies(ref("host", "db-01"))))
+	assert.Equal(t, []resource.Ref{ref("host", "web-01"), ref("service", "api")}, refsOf(inv.Dependents(ref("host", "db-01"))))
+}
+
+// Test_Inventory_TransitiveDependencies tests the TransitiveDependencies and
+// TransitiveDependents functions.
+func Test_Inventory_TransitiveDependencies(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	assert.Equal(t, []resource.Ref{ref("host", "db-01"), ref("host", "web-01")}, refsOf(inv.TransitiveDependencies(ref("service", "api"))))
+	assert.Equal(t, []resource.Ref{ref("host", "web-01"), ref("service", "api")}, refsOf(inv.TransitiveDependents(ref("host", "db-01"))))
+	assert.Equal(t, []resource.Ref{ref("ring", "a"), ref("ring", "b"), ref("ring", "c")}, refsOf(inv.TransitiveDependencies(ref("ring", "a"))))
+}
+
+// Test_Inventory_Cycles tests the Cycles function.
+func Test_Inventory_Cycles(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	assert.Equal(t, [][]resource.Ref{
+		{ref("ring", "a"), ref("ring", "b"), ref("ring", "c")},
+		{ref("ring", "self")},
+	}, inv.Cycles())
+}
+
+// Test_Inventory_AddResource_InvalidatesGraph tests that adding a resource
+// rebuilds the dependency graph.
+func Test_Inventory_AddResource_InvalidatesGraph(t *testing.T) {
+	t.Parallel()
+	inv := New()
+	inv.AddResource(&resource.Resource{Kind: "service", Name: "api", Data: refData("host/db-01")})
+	assert.Error(t, inv.ResolveRefs())
+	assert.Len(t, inv.Dependencies(ref("service", "api")), 0)
+	inv.AddResource(&resource.Resource{Kind: "host", Name: "db-01"})
+	assert.Len(t, inv.Dependencies(ref("service", "api")), 1)
+}
+
+// Test_Inventory_ResolveRefs_Errors tests the ResolveRefs function with
+// malformed and dangling references.
+func Test_Inventory_ResolveRefs_Errors(t *testing.T) {
+	t.Parallel()
+	inv := New()
+	inv.AddResource(&resource.Resource{Kind: "service", Name: "api", Data: refData("host/db-01", "host/db-02")})
+	inv.AddResource(&resource.Resource{Kind: "service", Name: "web", Data: refData("db-01")})
+	inv.AddResource(&resource.Resource{Kind: "host", Name: "db-01"})
+	err := inv.ResolveRefs()
+	var errs Errors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[0], ErrorDanglingRef)
+	assert.EqualError(t, errs[0], "[0]: service/api: data.b: dangling reference: host/db-02")
+	assert.ErrorIs(t, errs[1], resource.ErrorInvalidRef)
+	assert.Len(t, inv.Dependencies(ref("service", "api")), 1)
+}
+
+// Test_Inventory_Load_Refs tests that the inventory load function resolves
+// references.
+func Test_Inventory_Load_Refs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"hosts.yaml": `
+name: db-01
+host: {}
+`,
+		"services.yaml": `
+name: api
+service:
+  database:
+    ref: host/db-01
+---
+name: web
+service:
+  database: {ref: host/db-02}
+`,
+	})
+	_, err := Load(dir)
+	assert.EqualError(t, err, filepath.Join(dir, "services.yaml")+":7:1: service/web: data.database: dangling reference: host/db-02")
+	writeFiles(t, dir, map[string]string{
+		"hosts.yaml": `
+name: db-01
+host: {}
+---
+name: db-02
+host: {}
+`,
+	})
+	inv, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Ref{ref("service", "api")}, refsOf(inv.Dependents(ref("host", "db-01"))))
+	assert.Equal(t, []resource.Ref{ref("service", "web")}, refsOf(inv.Dependents(ref("host", "db-02"))))
+}
+
+// Test_Inventory_Subgraph tests the Subgraph function.
+func Test_Inventory_Subgraph(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	inv.Resources["host"]["db-01"].Owner = "dba"
+	sub, err := inv.Subgraph(SubgraphFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, sub.Nodes, 7)
+	assert.Equal(t, ref("host", "db-01"), sub.Nodes[0].Ref())
+	assert.Equal(t, []resource.Ref{ref("host", "db-01"), ref("host", "web-01")}, sub.Edges[ref("service", "api")])
+
+	sub, err = inv.Subgraph(SubgraphFilter{Kinds: []string{"service", "host"}, Owners: []string{""}})
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Ref{ref("host", "web-01"), ref("service", "api")}, refsOf(sub.Nodes))
+	assert.Equal(t, []resource.Ref{ref("host", "web-01")}, sub.Edges[ref("service", "api")])
+	assert.Equal(t, []resource.Ref{}, sub.Edges[ref("host", "web-01")])
+}
+
+// Test_Inventory_Subgraph_Root tests the Subgraph function with a root.
+func Test_Inventory_Subgraph_Root(t *testing.T) {
+	t.Parallel()
+	inv := newTestGraph()
+	root := ref("ring", "a")
+	sub, err := inv.Subgraph(SubgraphFilter{Root: &root})
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Ref{ref("ring", "a"), ref("ring", "b"), ref("ring", "c")}, refsOf(sub.Nodes))
+	sub, err = inv.Subgraph(SubgraphFilter{Root: &root, MaxDepth: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []resource.Ref{ref("ring", "a"), ref("ring", "b")}, refsOf(sub.Nodes))
+	assert.Equal(t, []resource.Ref{}, sub.Edges[ref("ring", "b")])
+	missing := ref("ring", "z")
+	_, err = inv.Subgraph(SubgraphFilter{Root: &missing})
+	assert.Error(t, err)
+}
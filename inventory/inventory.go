@@ -14,25 +14,119 @@
 
 package inventory
 
-import "github.com/neuralnorthwest/tpology/resource"
+import (
+	"fmt"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/neuralnorthwest/tpology/schema"
+)
+
+// MergePolicy decides what happens when a resource is defined more than once.
+type MergePolicy string
+
+const (
+	// MergeNone rejects resources that are already defined.
+	MergeNone MergePolicy = "none"
+	// MergeReplace replaces the resource with the one defined last.
+	MergeReplace MergePolicy = "replace"
+	// MergeDeep merges the resource defined last into the existing one. See
+	// resource.Resource.Merge.
+	MergeDeep MergePolicy = "deep"
+)
+
+// ParseMergePolicy parses a merge policy.
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	switch p := MergePolicy(s); p {
+	case MergeNone, MergeReplace, MergeDeep:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown merge policy: %s", s)
+	}
+}
 
 // Inventory is the inventory.
 type Inventory struct {
 	// Resources are the resources organized by kind and name.
 	Resources map[string]map[string]*resource.Resource
+	// Schemas are the schemas of the resource kinds.
+	Schemas *schema.Registry
+	// Columns are the named column sets organized by kind.
+	Columns map[string]ColumnSets
+	// MergePolicy decides what happens when a resource is defined more than
+	// once.
+	MergePolicy MergePolicy
+	// keepGoing makes Load return the partially loaded inventory along with
+	// the errors.
+	keepGoing bool
+	// graph is the dependency graph, built by ResolveRefs.
+	graph *graph
+}
+
+// Option is an inventory option.
+type Option func(*Inventory)
+
+// WithMergePolicy is an option to set the merge policy. Resources are loaded
+// in lexical order of their paths, so overlays should sort after the files
+// they override.
+func WithMergePolicy(p MergePolicy) Option {
+	return func(inv *Inventory) {
+		inv.MergePolicy = p
+	}
+}
+
+// WithKeepGoing is an option to make Load return the partially loaded
+// inventory along with the errors, instead of no inventory at all.
+func WithKeepGoing() Option {
+	return func(inv *Inventory) {
+		inv.keepGoing = true
+	}
 }
 
 // New returns a new inventory.
-func New() *Inventory {
-	return &Inventory{
-		Resources: make(map[string]map[string]*resource.Resource),
+func New(opts ...Option) *Inventory {
+	inv := &Inventory{
+		Resources:   make(map[string]map[string]*resource.Resource),
+		Schemas:     schema.NewRegistry(),
+		Columns:     make(map[string]ColumnSets),
+		MergePolicy: MergeNone,
+	}
+	for _, opt := range opts {
+		opt(inv)
 	}
+	return inv
 }
 
-// AddResource adds a resource to the inventory.
-func (inv *Inventory) AddResource(r *resource.Resource) {
+// AddResource adds a resource to the inventory. If the resource is already
+// defined, the merge policy decides whether it is rejected, replaced or
+// merged. A rejected resource yields a ResourceError naming both definitions.
+func (inv *Inventory) AddResource(r *resource.Resource) error {
 	if inv.Resources[r.Kind] == nil {
 		inv.Resources[r.Kind] = make(map[string]*resource.Resource)
 	}
+	if existing, ok := inv.Resources[r.Kind][r.Name]; ok {
+		switch inv.MergePolicy {
+		case MergeReplace:
+		case MergeDeep:
+			existing.Merge(r)
+			inv.graph = nil
+			return nil
+		default:
+			return newResourceError(r, fmt.Errorf("%w: already defined at %v", ErrorDuplicateResource, existing.Position()))
+		}
+	}
 	inv.Resources[r.Kind][r.Name] = r
+	inv.graph = nil
+	return nil
+}
+
+// Validate validates the data of every resource against the schema of its
+// kind. It returns an Errors holding a ValidationError for each violation.
+func (inv *Inventory) Validate() error {
+	errs := Errors{}
+	for _, r := range inv.sortedResources() {
+		for _, v := range inv.Schemas.Validate(r.Kind, "data", r.Data) {
+			errs = append(errs, newValidationError(r, v))
+		}
+	}
+	return errs.orNil()
 }
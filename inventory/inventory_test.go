@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/neuralnorthwest/tpology/schema"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,3 +42,47 @@ func Test_Inventory_AddResource(t *testing.T) {
 	assert.Len(t, inv.Resources["resource"], 1)
 	assert.Equal(t, "foo", inv.Resources["resource"]["foo"].Name)
 }
+
+// Test_Inventory_AddResource_Duplicate tests the inventory add resource
+// function with a duplicate resource.
+func Test_Inventory_AddResource_Duplicate(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []MergePolicy{MergeNone, MergeReplace, MergeDeep} {
+		inv := New(WithMergePolicy(policy))
+		assert.NoError(t, inv.AddResource(&resource.Resource{Kind: "resource", Name: "foo", Owner: "a"}))
+		err := inv.AddResource(&resource.Resource{Kind: "resource", Name: "foo", Owner: "b"})
+		if policy == MergeNone {
+			assert.ErrorIs(t, err, ErrorDuplicateResource)
+			assert.Equal(t, "a", inv.Resources["resource"]["foo"].Owner)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, "b", inv.Resources["resource"]["foo"].Owner)
+		}
+	}
+}
+
+// Test_ParseMergePolicy tests the ParseMergePolicy function.
+func Test_ParseMergePolicy(t *testing.T) {
+	t.Parallel()
+	p, err := ParseMergePolicy("deep")
+	assert.NoError(t, err)
+	assert.Equal(t, MergeDeep, p)
+	_, err = ParseMergePolicy("other")
+	assert.Error(t, err)
+}
+
+// Test_Inventory_Validate tests the inventory validate function.
+func Test_Inventory_Validate(t *testing.T) {
+	t.Parallel()
+	inv := New()
+	assert.NoError(t, inv.Validate())
+	inv.AddResource(&resource.Resource{
+		Kind: "resource",
+		Name: "foo",
+		Data: 1,
+	})
+	assert.NoError(t, inv.Validate())
+	inv.Schemas.Add("resource", &schema.Schema{Type: schema.Types{"string"}})
+	err := inv.Validate()
+	assert.EqualError(t, err, "[0]: resource/foo: data: expected string, got integer")
+}
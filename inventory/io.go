@@ -20,37 +20,75 @@ import (
 	"strings"
 
 	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/neuralnorthwest/tpology/schema"
 )
 
-// Load loads the inventory.
-func Load(path string) (*Inventory, error) {
-	inv := New()
+// SchemaDir is the directory of the inventory holding the schemas of the
+// resource kinds, one <kind>.yaml file per kind.
+const SchemaDir = "schemas"
+
+// Load loads the inventory and its column sets, validates the data of every
+// resource against the schema of its kind and resolves the references
+// between resources. Every manifest is loaded even if others fail, and all
+// problems are reported together as an Errors. By default no inventory is
+// returned along with the errors; see WithKeepGoing.
+func Load(path string, opts ...Option) (*Inventory, error) {
+	inv := New(opts...)
+	errs := Errors{}
+	schemaDir := filepath.Join(path, SchemaDir)
+	columnDir := filepath.Join(path, ColumnDir)
+	root := path
 	if err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		// Hidden files and directories, such as .git and the .itool.yaml
+		// configuration file, are not part of the inventory.
+		hidden := path != root && strings.HasPrefix(d.Name(), ".")
 		if d.IsDir() {
+			if hidden || path == schemaDir || path == columnDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden {
 			return nil
 		}
 		ext := strings.ToLower(filepath.Ext(path))
 		if ext != ".yaml" && ext != ".yml" {
 			return nil
 		}
-		return inv.LoadResourceFile(path)
+		errs = errs.append(inv.LoadResourceFile(path))
+		return nil
 	}); err != nil {
 		return nil, err
 	}
-	return inv, nil
+	schemas, err := schema.LoadDir(schemaDir)
+	if err != nil {
+		return nil, err
+	}
+	inv.Schemas = schemas
+	columns, err := LoadColumnDir(columnDir)
+	if err != nil {
+		return nil, err
+	}
+	inv.Columns = columns
+	errs = errs.append(inv.Validate())
+	errs = errs.append(inv.ResolveRefs())
+	if err := errs.orNil(); err != nil && !inv.keepGoing {
+		return nil, err
+	}
+	return inv, errs.orNil()
 }
 
-// LoadResourceFile loads resources from a manifest.
+// LoadResourceFile loads resources from a manifest. Documents that cannot be
+// loaded and resources that cannot be added to the inventory are skipped and
+// reported together as an Errors.
 func (inv *Inventory) LoadResourceFile(path string) error {
 	resources, err := resource.LoadFile(path)
-	if err != nil {
-		return err
-	}
+	errs := Errors{}.append(err)
 	for _, r := range resources {
-		inv.AddResource(r)
+		errs = errs.append(inv.AddResource(r))
 	}
-	return nil
+	return errs.orNil()
 }
@@ -15,15 +15,21 @@
 package inventory
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/neuralnorthwest/tpology/resource"
 	"github.com/stretchr/testify/assert"
 )
 
-// Test_Inventory_Load tests the inventory load function.
+// Test_Inventory_Load tests the inventory load function. The test data
+// defines resource/name twice, so the later definition must replace the
+// earlier one.
 func Test_Inventory_Load(t *testing.T) {
 	t.Parallel()
-	inv, err := Load("testdata")
+	inv, err := Load("testdata", WithMergePolicy(MergeReplace))
 	assert.Nil(t, err)
 	assert.NotNil(t, inv)
 	assert.Equal(t, 3, len(inv.Resources))
@@ -32,6 +38,180 @@ func Test_Inventory_Load(t *testing.T) {
 	assert.Equal(t, 1, len(inv.Resources["resource3"]))
 }
 
+// writeFiles writes files relative to dir, creating directories as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test_Inventory_Load_Schemas tests that the inventory load function loads
+// the schemas and does not load them as resources.
+func Test_Inventory_Load_Schemas(t *testing.T) {
+	t.Parallel()
+	inv, err := Load("testdata", WithMergePolicy(MergeReplace))
+	assert.Nil(t, err)
+	assert.Len(t, inv.Schemas.Schemas, 2)
+	assert.Nil(t, inv.Resources["type"])
+}
+
+// Test_Inventory_Load_Invalid tests the inventory load function with
+// resources that do not conform to their schema.
+func Test_Inventory_Load_Invalid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"schemas/host.yaml": `
+type: object
+required: [ip]
+properties:
+  ip:
+    type: string
+`,
+		"hosts.yaml": `
+name: db-01
+host:
+  ip: 10.0.0.1
+---
+name: db-02
+host:
+  ip: 2
+---
+name: db-03
+host:
+  address: 10.0.0.3
+`,
+	})
+	_, err := Load(dir)
+	var errs Errors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 2)
+	path := filepath.Join(dir, "hosts.yaml")
+	assert.EqualError(t, errs[0], path+":6:1: host/db-02: data.ip: expected string, got integer")
+	assert.EqualError(t, errs[1], path+`:10:1: host/db-03: data: missing required property "ip"`)
+	var verr *ValidationError
+	assert.ErrorAs(t, errs[0], &verr)
+	assert.Equal(t, 1, verr.Index)
+}
+
+// Test_Inventory_Load_InvalidSchema tests the inventory load function with an
+// invalid schema.
+func Test_Inventory_Load_InvalidSchema(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"schemas/host.yaml": "type: strange",
+	})
+	_, err := Load(dir)
+	assert.Error(t, err)
+}
+
+// Test_Inventory_Load_Duplicates tests that the inventory load function
+// reports every duplicate resource.
+func Test_Inventory_Load_Duplicates(t *testing.T) {
+	t.Parallel()
+	_, err := Load("testdata")
+	var errs Errors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrorDuplicateResource)
+	assert.EqualError(t, errs[0], filepath.Join("testdata", "single.yaml")+":15:1: resource/name: duplicate resource: already defined at "+filepath.Join("testdata", "multiple.yaml")+":15:1")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.yaml": "name: a\nhost: 1\n---\nname: b\nhost: 1\n",
+		"b.yaml": "name: a\nhost: 2\n---\nname: b\nhost: 2\n",
+		"c.yaml": "name: a\nhost: 3\n",
+	})
+	_, err = Load(dir)
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 3)
+}
+
+// Test_Inventory_Load_MergeDeep tests the inventory load function with the
+// deep merge policy.
+func Test_Inventory_Load_MergeDeep(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base/hosts.yaml": `
+name: db-01
+owner: platform
+host:
+  ip: 10.0.0.1
+  os: linux
+`,
+		"prod/hosts.yaml": `
+name: db-01
+host:
+  ip: 10.1.0.1
+`,
+	})
+	inv, err := Load(dir, WithMergePolicy(MergeDeep))
+	assert.NoError(t, err)
+	r := inv.Resources["host"]["db-01"]
+	assert.Equal(t, "platform", r.Owner)
+	assert.Equal(t, map[string]interface{}{"ip": "10.1.0.1", "os": "linux"}, r.Data)
+	assert.Equal(t, filepath.Join(dir, "base", "hosts.yaml"), r.LoadedFrom())
+}
+
+// Test_Inventory_Load_Aggregated tests that the inventory load function
+// reports the problems of every manifest, and returns the partial inventory
+// with the keep going option.
+func Test_Inventory_Load_Aggregated(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.yaml": "name: a\nhost: {}\n---\nname: b\nhost: {}\nservice: {}\n",
+		"b.yaml": "name: c\nhost: [\n",
+		"c.yaml": "name: d\nhost: {ref: host/z}\n",
+	})
+	inv, err := Load(dir)
+	assert.Nil(t, inv)
+	var errs Errors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 3)
+	groups := errs.ByFile()
+	assert.Len(t, groups, 3)
+	assert.Equal(t, filepath.Join(dir, "a.yaml"), groups[0].Path)
+	assert.Equal(t, filepath.Join(dir, "b.yaml"), groups[1].Path)
+	assert.Equal(t, filepath.Join(dir, "c.yaml"), groups[2].Path)
+	var loadErr *resource.LoadError
+	assert.ErrorAs(t, groups[0].Errors[0], &loadErr)
+	assert.Equal(t, 1, loadErr.Index)
+	assert.Equal(t, 4, loadErr.Line)
+	assert.ErrorIs(t, groups[2].Errors[0], ErrorDanglingRef)
+
+	inv, err = Load(dir, WithKeepGoing())
+	assert.Error(t, err)
+	assert.NotNil(t, inv)
+	assert.Len(t, inv.Resources["host"], 2)
+}
+
+// Test_Errors_ByFile tests the Errors ByFile function.
+func Test_Errors_ByFile(t *testing.T) {
+	t.Parallel()
+	errs := Errors{}.append(Errors{
+		&resource.LoadError{Position: resource.Position{Path: "b.yaml"}},
+		errors.New("no file"),
+		&ResourceError{Position: resource.Position{Path: "a.yaml"}},
+		&ValidationError{Position: resource.Position{Path: "b.yaml"}},
+	})
+	groups := errs.ByFile()
+	assert.Len(t, groups, 3)
+	assert.Equal(t, "b.yaml", groups[0].Path)
+	assert.Len(t, groups[0].Errors, 2)
+	assert.Equal(t, "", groups[1].Path)
+	assert.Equal(t, "a.yaml", groups[2].Path)
+}
+
 // Test_Inventory_Load_Nonexistent tests the inventory load function with a
 // nonexistent directory.
 func Test_Inventory_Load_Nonexistent(t *testing.T) {
@@ -40,6 +220,33 @@ func Test_Inventory_Load_Nonexistent(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+// Test_Inventory_Load_SkipGitDir tests that the inventory load function skips
+// the .git directory.
+func Test_Inventory_Load_SkipGitDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "corrupted.yaml"), []byte("corrupted"), 0644))
+	inv, err := Load(dir)
+	assert.Nil(t, err)
+	assert.Len(t, inv.Resources, 0)
+}
+
+// Test_Inventory_Load_SkipHidden tests that the inventory load function
+// skips hidden files and directories, such as the .itool.yaml configuration
+// file at the root of the inventory.
+func Test_Inventory_Load_SkipHidden(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".itool.yaml"), []byte("profiles:\n  work:\n    inventory-ref: main\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".github"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".github", "ci.yaml"), []byte("on: push\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "host.yaml"), []byte("host:\n  name: db-01\n"), 0644))
+	inv, err := Load(dir)
+	assert.NoError(t, err)
+	assert.Len(t, inv.Resources, 1)
+}
+
 // Test_Inventory_LoadResourceFile tests the inventory load resource file
 // function.
 func Test_Inventory_LoadResourceFile(t *testing.T) {
@@ -0,0 +1,32 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"github.com/neuralnorthwest/tpology/query"
+	"github.com/neuralnorthwest/tpology/resource"
+)
+
+// Query returns the resources of the inventory selected by the query, in the
+// order of the query.
+func (inv *Inventory) Query(q *query.Query) []*resource.Resource {
+	resources := []*resource.Resource{}
+	for _, byName := range inv.Resources {
+		for _, r := range byName {
+			resources = append(resources, r)
+		}
+	}
+	return q.Run(resources)
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"testing"
+
+	"github.com/neuralnorthwest/tpology/query"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Inventory_Query tests the Inventory Query function.
+func Test_Inventory_Query(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"hosts.yaml": `
+name: db-01
+owner: platform
+host:
+  region: us-east
+---
+name: db-02
+owner: platform
+host:
+  region: eu-west
+---
+name: web-01
+owner: web
+host:
+  region: us-west
+`,
+		"services.yaml": `
+name: api
+owner: platform
+service:
+  region: us-east
+`,
+	})
+	inv, err := Load(dir)
+	assert.NoError(t, err)
+	q, err := query.New(`owner == "platform" && data.region in ["us-east", "us-west"]`, "", 0)
+	assert.NoError(t, err)
+	resources := inv.Query(q)
+	if assert.Len(t, resources, 2) {
+		assert.Equal(t, "db-01", resources[0].Name)
+		assert.Equal(t, "api", resources[1].Name)
+	}
+}
@@ -24,5 +24,6 @@ import (
 func main() {
 	if err := cmd.Main(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,282 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/resource"
+)
+
+// Expr is an expression evaluated against a resource.
+type Expr interface {
+	// Eval returns the value of the expression for the resource.
+	Eval(r *resource.Resource) interface{}
+	// String returns the source form of the expression.
+	String() string
+}
+
+// Match returns true if the expression is truthy for the resource. A nil
+// expression matches every resource.
+func Match(e Expr, r *resource.Resource) bool {
+	if e == nil {
+		return true
+	}
+	return truthy(e.Eval(r))
+}
+
+// And returns the conjunction of the expressions, leaving out nil ones. It
+// returns nil if every expression is nil.
+func And(exprs ...Expr) Expr {
+	var result Expr
+	for _, e := range exprs {
+		switch {
+		case e == nil:
+		case result == nil:
+			result = e
+		default:
+			result = &binary{op: "&&", left: result, right: e}
+		}
+	}
+	return result
+}
+
+// literal is a string, number, boolean or null literal.
+type literal struct {
+	value interface{}
+}
+
+// Eval returns the literal value.
+func (e *literal) Eval(r *resource.Resource) interface{} {
+	return e.value
+}
+
+// String returns the source form of the literal.
+func (e *literal) String() string {
+	switch v := e.value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// path is a dotted path into the resource. See resource.Resource.Field.
+type path struct {
+	path string
+}
+
+// Eval returns the value at the path, or nil if there is none.
+func (e *path) Eval(r *resource.Resource) interface{} {
+	v, ok := r.Field(e.path)
+	if !ok {
+		return nil
+	}
+	return normalize(v)
+}
+
+// String returns the path.
+func (e *path) String() string {
+	return e.path
+}
+
+// list is a list literal.
+type list struct {
+	elems []Expr
+}
+
+// Eval returns the values of the elements.
+func (e *list) Eval(r *resource.Resource) interface{} {
+	values := make([]interface{}, len(e.elems))
+	for i, elem := range e.elems {
+		values[i] = elem.Eval(r)
+	}
+	return values
+}
+
+// String returns the source form of the list.
+func (e *list) String() string {
+	elems := make([]string, len(e.elems))
+	for i, elem := range e.elems {
+		elems[i] = elem.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// not is the logical negation of an expression.
+type not struct {
+	x Expr
+}
+
+// Eval returns true if the operand is falsy.
+func (e *not) Eval(r *resource.Resource) interface{} {
+	return !truthy(e.x.Eval(r))
+}
+
+// String returns the source form of the negation.
+func (e *not) String() string {
+	return "!" + e.x.String()
+}
+
+// binary is a binary operation.
+type binary struct {
+	op    string
+	left  Expr
+	right Expr
+}
+
+// Eval returns the result of the operation. Logical operators short-circuit.
+func (e *binary) Eval(r *resource.Resource) interface{} {
+	switch e.op {
+	case "&&":
+		return truthy(e.left.Eval(r)) && truthy(e.right.Eval(r))
+	case "||":
+		return truthy(e.left.Eval(r)) || truthy(e.right.Eval(r))
+	}
+	left, right := e.left.Eval(r), e.right.Eval(r)
+	switch e.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "in":
+		return in(left, right)
+	case "not in":
+		return !in(left, right)
+	default:
+		c, ok := compare(left, right)
+		if !ok {
+			return false
+		}
+		switch e.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}
+}
+
+// String returns the source form of the operation.
+func (e *binary) String() string {
+	return "(" + e.left.String() + " " + e.op + " " + e.right.String() + ")"
+}
+
+// match is a regular expression match.
+type match struct {
+	x  Expr
+	re *regexp.Regexp
+}
+
+// Eval returns true if the operand is a string matching the regular
+// expression.
+func (e *match) Eval(r *resource.Resource) interface{} {
+	s, ok := e.x.Eval(r).(string)
+	return ok && e.re.MatchString(s)
+}
+
+// String returns the source form of the match.
+func (e *match) String() string {
+	return "(" + e.x.String() + " =~ " + strconv.Quote(e.re.String()) + ")"
+}
+
+// truthy returns the truth value of a value: nil and false are false, and
+// anything else is true.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	default:
+		return true
+	}
+}
+
+// normalize converts the numbers of a value to float64, so that numbers
+// decoded from YAML compare equal to number literals.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = normalize(e)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// equal returns true if the values are equal. Values of different types are
+// never equal.
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// in returns true if the list b contains a.
+func in(a, b interface{}) bool {
+	values, ok := b.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range values {
+		if equal(a, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares two numbers or two strings. It returns false if the values
+// cannot be ordered.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	// tokenEOF is the end of the input.
+	tokenEOF tokenKind = iota
+	// tokenIdent is an identifier or a dotted path.
+	tokenIdent
+	// tokenString is a quoted string.
+	tokenString
+	// tokenNumber is a number.
+	tokenNumber
+	// tokenOp is an operator or punctuation.
+	tokenOp
+)
+
+// token is a lexical token.
+type token struct {
+	// kind is the kind of the token.
+	kind tokenKind
+	// text is the text of the token. For strings, it is the unquoted value.
+	text string
+	// pos is the offset of the token in the input.
+	pos int
+}
+
+// String returns a description of the token for error messages.
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators are the operators and punctuation, longest first.
+var operators = []string{
+	"&&", "||", "==", "!=", "<=", ">=", "=~",
+	"<", ">", "!", "(", ")", "[", "]", ",",
+}
+
+// lex splits the input into tokens.
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := lexString(input[i:])
+			if err != nil {
+				return nil, fmt.Errorf("offset %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s, pos: i})
+			i += n
+		case isDigit(c) || (c == '-' && i+1 < len(input) && isDigit(rune(input[i+1]))):
+			start := i
+			i++
+			for i < len(input) && (isDigit(rune(input[i])) || input[i] == '.' || input[i] == 'e' || input[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: input[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentPart(rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: input[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(input[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("offset %d: unexpected character %q", i, c)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// lexString lexes a quoted string, returning its value and length. Quotes and
+// backslashes can be escaped with a backslash.
+func lexString(input string) (string, int, error) {
+	quote := input[0]
+	b := &strings.Builder{}
+	for i := 1; i < len(input); i++ {
+		switch input[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(input) {
+				i++
+			}
+			b.WriteByte(input[i])
+		default:
+			b.WriteByte(input[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// isDigit returns true if c is a decimal digit.
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentStart returns true if c can start an identifier.
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+// isIdentPart returns true if c can be part of a dotted path. Dashes and
+// slashes are allowed since they are common in data keys and label names.
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == '-' || c == '/'
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Error is a query error.
+type Error string
+
+// Error returns the error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	// ErrorSyntax is the error returned when an expression is malformed.
+	ErrorSyntax = Error("syntax error")
+)
+
+// Parse parses a where expression. The grammar, from lowest to highest
+// precedence, is:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" | "not in" ) operand
+//	                  | "=~" string ]
+//	operand = "(" expr ")" | "[" [ operand { "," operand } ] "]"
+//	        | string | number | "true" | "false" | "null" | path
+//
+// A path is a dotted path into the resource, such as owner or data.region.
+func Parse(where string) (Expr, error) {
+	tokens, err := lex(where)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorSyntax, err)
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+// parser is a recursive descent parser over a list of tokens.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the operator op or returns an error.
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %s", op, t)
+	}
+	return nil
+}
+
+// errorf returns a syntax error at the token.
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: offset %d: %s", ErrorSyntax, t.pos, fmt.Sprintf(format, args...))
+}
+
+// parseOr parses a disjunction.
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a conjunction.
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses a negation or a comparison.
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept("!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+// parseCompare parses a comparison, or a single operand.
+func (p *parser) parseCompare() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokenOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		op = t.text
+	case t.kind == tokenOp && t.text == "=~":
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, p.errorf(pattern, "expected regular expression string, got %s", pattern)
+		}
+		re, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, p.errorf(pattern, "%s", err)
+		}
+		return &match{x: left, re: re}, nil
+	case t.kind == tokenIdent && t.text == "in":
+		op = "in"
+	case t.kind == tokenIdent && t.text == "not":
+		p.next()
+		if t := p.peek(); t.kind != tokenIdent || t.text != "in" {
+			return nil, p.errorf(t, "expected \"in\", got %s", t)
+		}
+		op = "not in"
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &binary{op: op, left: left, right: right}, nil
+}
+
+// parseOperand parses a parenthesized expression, a list, a literal or a
+// path.
+func (p *parser) parseOperand() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t)
+		}
+		return &literal{value: f}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		case "in", "not":
+			return nil, p.errorf(t, "unexpected %s", t)
+		}
+		return &path{path: t.text}, nil
+	case tokenOp:
+		switch t.text {
+		case "(":
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		case "[":
+			l := &list{elems: []Expr{}}
+			if p.accept("]") {
+				return l, nil
+			}
+			for {
+				e, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				l.elems = append(l.elems, e)
+				if p.accept("]") {
+					return l, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nil, p.errorf(t, "unexpected %s", t)
+}
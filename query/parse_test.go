@@ -0,0 +1,126 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"testing"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// newHost returns a host resource for tests.
+func newHost(t *testing.T, name, owner string, data map[string]interface{}) *resource.Resource {
+	t.Helper()
+	r, err := resource.New("host", name, "", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Data = data
+	return r
+}
+
+// Test_Parse tests the Parse function.
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		where string
+		want  string
+	}{
+		{`owner == "platform"`, `(owner == "platform")`},
+		{`a || b && !c`, `(a || (b && !c))`},
+		{`(a || b) && c`, `((a || b) && c)`},
+		{`data.cpus >= 4`, `(data.cpus >= 4)`},
+		{`data.region in ["us-east", 'us-west']`, `(data.region in ["us-east", "us-west"])`},
+		{`name not in []`, `(name not in [])`},
+		{`name =~ "^db-"`, `(name =~ "^db-")`},
+		{`data.x == null || data.y != true`, `((data.x == null) || (data.y != true))`},
+		{`data.temp > -1.5`, `(data.temp > -1.5)`},
+		{`"a \"b\""`, `"a \"b\""`},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.where)
+		if assert.NoError(t, err, c.where) {
+			assert.Equal(t, c.want, e.String(), c.where)
+		}
+	}
+}
+
+// Test_Parse_Errors tests the Parse function with malformed expressions.
+func Test_Parse_Errors(t *testing.T) {
+	t.Parallel()
+	for _, where := range []string{
+		``,
+		`owner ==`,
+		`owner == "x`,
+		`(a`,
+		`[a b]`,
+		`a not b`,
+		`name =~ x`,
+		`name =~ "("`,
+		`a b`,
+		`a & b`,
+		`in`,
+	} {
+		_, err := Parse(where)
+		assert.ErrorIs(t, err, ErrorSyntax, where)
+	}
+}
+
+// Test_Match tests the Match function.
+func Test_Match(t *testing.T) {
+	t.Parallel()
+	r := newHost(t, "db-01", "platform", map[string]interface{}{
+		"region": "us-east",
+		"cpus":   8,
+		"memory": 15.5,
+		"tags":   []interface{}{"db", 1},
+		"public": false,
+	})
+	cases := []struct {
+		where string
+		want  bool
+	}{
+		{`owner == "platform" && data.region in ["us-east", "us-west"]`, true},
+		{`owner == "platform" && data.region in ["eu-west"]`, false},
+		{`data.region not in ["eu-west"]`, true},
+		{`kind == "host" && name == "db-01"`, true},
+		{`data.cpus == 8`, true},
+		{`data.cpus > 4 && data.cpus <= 8`, true},
+		{`data.cpus < 8`, false},
+		{`data.memory >= 15.5`, true},
+		{`data.cpus == "8"`, false},
+		{`data.cpus > "4"`, false},
+		{`name < "db-02"`, true},
+		{`name =~ "^db-\\d+$"`, true},
+		{`data.cpus =~ "8"`, false},
+		{`"db" in data.tags && 1 in data.tags`, true},
+		{`data.public`, false},
+		{`!data.public`, true},
+		{`data.public == false`, true},
+		{`data.missing`, false},
+		{`data.missing == null`, true},
+		{`data.region`, true},
+		{`owner in "platform"`, false},
+		{`data.cpus > 100 || description == ""`, true},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.where)
+		if assert.NoError(t, err, c.where) {
+			assert.Equal(t, c.want, Match(e, r), c.where)
+		}
+	}
+	assert.True(t, Match(nil, r))
+}
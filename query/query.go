@@ -0,0 +1,135 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/resource"
+)
+
+// SortKey is a key to sort resources by.
+type SortKey struct {
+	// Path is the dotted path of the value to sort by.
+	Path string
+	// Descending sorts in descending order.
+	Descending bool
+}
+
+// ParseSortBy parses a comma separated list of sort keys. A key prefixed with
+// "-" sorts in descending order, e.g. "owner,-data.cpus".
+func ParseSortBy(s string) ([]SortKey, error) {
+	keys := []SortKey{}
+	if strings.TrimSpace(s) == "" {
+		return keys, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		key := SortKey{Path: field}
+		if strings.HasPrefix(field, "-") {
+			key = SortKey{Path: field[1:], Descending: true}
+		}
+		if key.Path == "" {
+			return nil, fmt.Errorf("%w: empty sort key in %q", ErrorSyntax, s)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Query selects, sorts and limits resources.
+type Query struct {
+	// Where selects the resources. Nil selects every resource.
+	Where Expr
+	// SortBy are the keys to sort the selected resources by. Ties are broken
+	// by kind and name.
+	SortBy []SortKey
+	// Limit is the maximum number of resources returned. Zero means no limit.
+	Limit int
+}
+
+// New returns a query from its textual form. Empty where and sortBy select
+// every resource in kind and name order.
+func New(where, sortBy string, limit int) (*Query, error) {
+	q := &Query{Limit: limit}
+	if limit < 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+	if strings.TrimSpace(where) != "" {
+		e, err := Parse(where)
+		if err != nil {
+			return nil, err
+		}
+		q.Where = e
+	}
+	keys, err := ParseSortBy(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	q.SortBy = keys
+	return q, nil
+}
+
+// Run returns the resources selected by the query, in order. The input slice
+// is not modified.
+func (q *Query) Run(resources []*resource.Resource) []*resource.Resource {
+	selected := []*resource.Resource{}
+	for _, r := range resources {
+		if Match(q.Where, r) {
+			selected = append(selected, r)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		for _, key := range q.SortBy {
+			c, present := compareField(selected[i], selected[j], key.Path)
+			if c == 0 {
+				continue
+			}
+			if key.Descending && present {
+				return c > 0
+			}
+			return c < 0
+		}
+		return selected[i].Ref().Less(selected[j].Ref())
+	})
+	if q.Limit > 0 && len(selected) > q.Limit {
+		selected = selected[:q.Limit]
+	}
+	return selected
+}
+
+// compareField compares the values at a path of two resources, and returns
+// whether both values are present. Missing values sort last regardless of the
+// sort direction, and values that cannot be ordered compare by their string
+// form.
+func compareField(a, b *resource.Resource, path string) (int, bool) {
+	va, okA := a.Field(path)
+	vb, okB := b.Field(path)
+	switch {
+	case !okA && !okB:
+		return 0, false
+	case !okA:
+		return 1, false
+	case !okB:
+		return -1, false
+	}
+	va, vb = normalize(va), normalize(vb)
+	if c, ok := compare(va, vb); ok {
+		return c, true
+	}
+	return strings.Compare(fmt.Sprint(va), fmt.Sprint(vb)), true
+}
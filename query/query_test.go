@@ -0,0 +1,89 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"testing"
+
+	"github.com/neuralnorthwest/tpology/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// names returns the names of the resources.
+func names(resources []*resource.Resource) []string {
+	n := make([]string, len(resources))
+	for i, r := range resources {
+		n[i] = r.Name
+	}
+	return n
+}
+
+// Test_ParseSortBy tests the ParseSortBy function.
+func Test_ParseSortBy(t *testing.T) {
+	t.Parallel()
+	keys, err := ParseSortBy("owner, -data.cpus")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortKey{{Path: "owner"}, {Path: "data.cpus", Descending: true}}, keys)
+	keys, err = ParseSortBy("")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	_, err = ParseSortBy("owner,,name")
+	assert.ErrorIs(t, err, ErrorSyntax)
+	_, err = ParseSortBy("-")
+	assert.ErrorIs(t, err, ErrorSyntax)
+}
+
+// Test_New tests the New function.
+func Test_New(t *testing.T) {
+	t.Parallel()
+	q, err := New("", "", 0)
+	assert.NoError(t, err)
+	assert.Nil(t, q.Where)
+	_, err = New("owner ==", "", 0)
+	assert.ErrorIs(t, err, ErrorSyntax)
+	_, err = New("", "", -1)
+	assert.Error(t, err)
+}
+
+// Test_Query_Run tests the Query Run function.
+func Test_Query_Run(t *testing.T) {
+	t.Parallel()
+	resources := []*resource.Resource{
+		newHost(t, "c", "platform", map[string]interface{}{"cpus": 4}),
+		newHost(t, "a", "platform", map[string]interface{}{"cpus": 16}),
+		newHost(t, "d", "data", map[string]interface{}{}),
+		newHost(t, "b", "data", map[string]interface{}{"cpus": 8}),
+	}
+	q, err := New("", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names(q.Run(resources)))
+	assert.Equal(t, "c", resources[0].Name)
+
+	q, err = New(`owner == "platform"`, "data.cpus", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, names(q.Run(resources)))
+
+	q, err = New("", "-data.cpus", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names(q.Run(resources)))
+
+	q, err = New("", "data.cpus", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a", "d"}, names(q.Run(resources)))
+
+	q, err = New("", "owner,-name", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "b"}, names(q.Run(resources)))
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neuralnorthwest/tpology/resource"
+)
+
+// requirement is a single requirement of a label selector.
+type requirement struct {
+	// key is the label key.
+	key string
+	// op is one of "exists", "!exists", "=", "!=", "in" and "notin".
+	op string
+	// values are the values compared to the label value.
+	values []string
+}
+
+// matches returns true if the labels satisfy the requirement. As in
+// Kubernetes, "!=" and "notin" match resources without the label.
+func (req requirement) matches(labels map[string]string) bool {
+	value, ok := labels[req.key]
+	switch req.op {
+	case "exists":
+		return ok
+	case "!exists":
+		return !ok
+	case "=", "in":
+		return ok && containsString(req.values, value)
+	default:
+		return !ok || !containsString(req.values, value)
+	}
+}
+
+// String returns the source form of the requirement.
+func (req requirement) String() string {
+	switch req.op {
+	case "exists":
+		return req.key
+	case "!exists":
+		return "!" + req.key
+	case "=", "!=":
+		return req.key + req.op + req.values[0]
+	default:
+		return req.key + " " + req.op + " (" + strings.Join(req.values, ",") + ")"
+	}
+}
+
+// selector is a label selector. It matches resources satisfying every
+// requirement.
+type selector []requirement
+
+// Eval returns true if the labels of the resource satisfy the selector.
+func (s selector) Eval(r *resource.Resource) interface{} {
+	for _, req := range s {
+		if !req.matches(r.Labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the source form of the selector.
+func (s selector) String() string {
+	reqs := make([]string, len(s))
+	for i, req := range s {
+		reqs[i] = req.String()
+	}
+	return strings.Join(reqs, ",")
+}
+
+// ParseSelector parses a Kubernetes style label selector: a comma separated
+// list of requirements that must all be satisfied, e.g.
+// "env=prod,tier!=cache". The requirements are:
+//
+//	key              the label is set
+//	!key             the label is not set
+//	key=value        the label is value (also key==value)
+//	key!=value       the label is not value, or not set
+//	key in (a,b)     the label is one of the values
+//	key notin (a,b)  the label is none of the values, or not set
+func ParseSelector(s string) (Expr, error) {
+	sel := selector{}
+	for _, part := range splitSelector(s) {
+		req, err := parseRequirement(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%w: selector %q: %s", ErrorSyntax, s, err)
+		}
+		sel = append(sel, req)
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("%w: empty selector", ErrorSyntax)
+	}
+	return sel, nil
+}
+
+// splitSelector splits a selector on the commas outside parentheses.
+func splitSelector(s string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(s) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// parseRequirement parses a single requirement.
+func parseRequirement(s string) (requirement, error) {
+	if strings.HasPrefix(s, "!") {
+		key := strings.TrimSpace(s[1:])
+		return requirement{key: key, op: "!exists"}, validateLabel("key", key)
+	}
+	if fields := strings.Fields(s); len(fields) >= 2 && (fields[1] == "in" || fields[1] == "notin") {
+		key, op := fields[0], fields[1]
+		list := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s[len(key):]), op))
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return requirement{}, fmt.Errorf("expected parenthesized values after %q", op)
+		}
+		req := requirement{key: key, op: op, values: []string{}}
+		for _, value := range strings.Split(list[1:len(list)-1], ",") {
+			value = strings.TrimSpace(value)
+			if err := validateLabel("value", value); err != nil {
+				return requirement{}, err
+			}
+			req.values = append(req.values, value)
+		}
+		return req, validateLabel("key", key)
+	}
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(s, op); ok {
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if err := validateLabel("key", key); err != nil {
+				return requirement{}, err
+			}
+			if op == "==" {
+				op = "="
+			}
+			return requirement{key: key, op: op, values: []string{value}}, validateLabel("value", value)
+		}
+	}
+	return requirement{key: s, op: "exists"}, validateLabel("key", s)
+}
+
+// validateLabel returns an error if a label key or value holds characters
+// reserved by the selector syntax. Keys must not be empty.
+func validateLabel(what, s string) error {
+	if what == "key" && s == "" {
+		return fmt.Errorf("empty key")
+	}
+	if i := strings.IndexAny(s, "!=(), \t"); i >= 0 {
+		return fmt.Errorf("invalid character %q in %s %q", s[i], what, s)
+	}
+	return nil
+}
+
+// containsString returns true if the values contain value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
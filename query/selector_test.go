@@ -0,0 +1,109 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_ParseSelector tests the ParseSelector function.
+func Test_ParseSelector(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		selector string
+		want     string
+	}{
+		{"env=prod,tier!=cache", "env=prod,tier!=cache"},
+		{" env == prod , !legacy ", "env=prod,!legacy"},
+		{"tier in (db, web),zone notin (a)", "tier in (db,web),zone notin (a)"},
+		{"app.kubernetes.io/name", "app.kubernetes.io/name"},
+		{"env=", "env="},
+	}
+	for _, c := range cases {
+		sel, err := ParseSelector(c.selector)
+		if assert.NoError(t, err, c.selector) {
+			assert.Equal(t, c.want, sel.String(), c.selector)
+		}
+	}
+}
+
+// Test_ParseSelector_Errors tests the ParseSelector function with malformed
+// selectors.
+func Test_ParseSelector_Errors(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{
+		"",
+		"env=prod,",
+		"=prod",
+		"!",
+		"env=a=b",
+		"tier in db",
+		"tier in (a b)",
+		"tier notin (a",
+		"a b",
+	} {
+		_, err := ParseSelector(s)
+		assert.ErrorIs(t, err, ErrorSyntax, s)
+	}
+}
+
+// Test_Selector_Match tests matching resources with label selectors.
+func Test_Selector_Match(t *testing.T) {
+	t.Parallel()
+	r := newHost(t, "db-01", "platform", nil)
+	r.Labels = map[string]string{"env": "prod", "tier": "db"}
+	cases := []struct {
+		selector string
+		want     bool
+	}{
+		{"env=prod", true},
+		{"env=prod,tier!=cache", true},
+		{"env=prod,tier!=db", false},
+		{"zone!=a", true},
+		{"env", true},
+		{"zone", false},
+		{"!zone", true},
+		{"!env", false},
+		{"tier in (db,web)", true},
+		{"tier in (web)", false},
+		{"zone in (a)", false},
+		{"tier notin (web)", true},
+		{"tier notin (db)", false},
+		{"zone notin (a)", true},
+	}
+	for _, c := range cases {
+		sel, err := ParseSelector(c.selector)
+		if assert.NoError(t, err, c.selector) {
+			assert.Equal(t, c.want, Match(sel, r), c.selector)
+		}
+	}
+	where, err := Parse(`labels.env == "prod" && labels.zone == null`)
+	assert.NoError(t, err)
+	assert.True(t, Match(where, r))
+}
+
+// Test_And tests the And function.
+func Test_And(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, And(nil, nil))
+	a, err := Parse("a")
+	assert.NoError(t, err)
+	b, err := Parse("b")
+	assert.NoError(t, err)
+	assert.Equal(t, a, And(nil, a))
+	assert.Equal(t, "(a && b)", And(a, nil, b).String())
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalDocument returns the YAML document of the resource, as written to a
+// manifest. A resource loaded from a manifest is written back through the
+// YAML node of its document, so that the order of keys, comments, anchors
+// and styles of unchanged values are kept. Other resources list the standard
+// fields first, in the order name, description, owner, labels and
+// annotations, followed by the data under the kind, leaving out empty
+// standard fields. The node of the document is only updated once the
+// resource is marshaled.
+func (r *Resource) MarshalDocument() ([]byte, error) {
+	doc := copyNode(r.doc)
+	if doc == nil {
+		doc = &yaml.Node{Kind: yaml.DocumentNode}
+	}
+	node, err := r.node(doc)
+	if err != nil {
+		return nil, err
+	}
+	doc.Content = []*yaml.Node{node}
+	b := &bytes.Buffer{}
+	enc := yaml.NewEncoder(b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	if r.doc != nil {
+		r.doc = doc
+	}
+	return b.Bytes(), nil
+}
+
+// node returns the mapping node of the resource. The mapping node of doc, if
+// any, is updated in place with the fields of the resource.
+func (r *Resource) node(doc *yaml.Node) (*yaml.Node, error) {
+	var m *yaml.Node
+	if doc != nil && len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		m = doc.Content[0]
+	} else {
+		m = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	// Rename the kind key, so that the data is updated in place.
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if key := m.Content[i]; !KindIsReservedWord(key.Value) {
+			key.Value = r.Kind
+		}
+	}
+	fields := []struct {
+		key   string
+		value interface{}
+		empty bool
+	}{
+		{"name", r.Name, false},
+		{"description", r.Description, r.Description == ""},
+		{"owner", r.Owner, r.Owner == ""},
+		{"labels", stringMap(r.Labels), len(r.Labels) == 0},
+		{"annotations", stringMap(r.Annotations), len(r.Annotations) == 0},
+		{r.Kind, r.Data, false},
+	}
+	for i, f := range fields {
+		index := findKey(m, f.key)
+		switch {
+		case index >= 0 && f.empty && i >= 3:
+			// Empty labels and annotations are removed. Empty description
+			// and owner keys are kept as written.
+			m.Content = append(m.Content[:index], m.Content[index+2:]...)
+		case index >= 0:
+			value, err := updateNode(m.Content[index+1], f.value)
+			if err != nil {
+				return nil, err
+			}
+			m.Content[index+1] = value
+		case !f.empty:
+			key, value, err := newPair(f.key, f.value)
+			if err != nil {
+				return nil, err
+			}
+			// Insert the key after the preceding standard fields.
+			at := 0
+			for _, prev := range fields[:i] {
+				if j := findKey(m, prev.key); j >= 0 && j+2 > at {
+					at = j + 2
+				}
+			}
+			m.Content = append(m.Content[:at], append([]*yaml.Node{key, value}, m.Content[at:]...)...)
+		}
+	}
+	return m, nil
+}
+
+// copyNode returns a deep copy of a YAML node, or nil if the node is nil.
+// Aliases point to the copies of their anchors.
+func copyNode(n *yaml.Node) *yaml.Node {
+	copies := map[*yaml.Node]*yaml.Node{}
+	var dup func(n *yaml.Node) *yaml.Node
+	dup = func(n *yaml.Node) *yaml.Node {
+		if n == nil {
+			return nil
+		}
+		if c, ok := copies[n]; ok {
+			return c
+		}
+		c := &yaml.Node{}
+		*c = *n
+		copies[n] = c
+		c.Alias = dup(n.Alias)
+		if n.Content != nil {
+			c.Content = make([]*yaml.Node, len(n.Content))
+			for i, child := range n.Content {
+				c.Content[i] = dup(child)
+			}
+		}
+		return c
+	}
+	return dup(n)
+}
+
+// findKey returns the index of the key in a mapping node, or -1.
+func findKey(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// newPair returns the key and value nodes of a mapping entry.
+func newPair(key string, value interface{}) (*yaml.Node, *yaml.Node, error) {
+	v := &yaml.Node{}
+	if err := v.Encode(value); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v, nil
+}
+
+// updateNode returns the node updated to hold the value. A node already
+// holding the value is returned unchanged. Mappings and sequences are updated
+// entry by entry, and other nodes are replaced, keeping their comments.
+func updateNode(n *yaml.Node, value interface{}) (*yaml.Node, error) {
+	var current interface{}
+	if err := n.Decode(&current); err == nil && reflect.DeepEqual(current, value) {
+		return n, nil
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if n.Kind == yaml.MappingNode && findKey(n, "<<") < 0 {
+			content := []*yaml.Node{}
+			seen := map[string]bool{}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				e, ok := v[key]
+				if !ok {
+					continue
+				}
+				value, err := updateNode(n.Content[i+1], e)
+				if err != nil {
+					return nil, err
+				}
+				content = append(content, n.Content[i], value)
+				seen[key] = true
+			}
+			keys := []string{}
+			for k := range v {
+				if !seen[k] {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				key, value, err := newPair(k, v[k])
+				if err != nil {
+					return nil, err
+				}
+				content = append(content, key, value)
+			}
+			n.Content = content
+			return n, nil
+		}
+	case []interface{}:
+		if n.Kind == yaml.SequenceNode {
+			content := []*yaml.Node{}
+			for i, e := range v {
+				if i >= len(n.Content) {
+					value := &yaml.Node{}
+					if err := value.Encode(e); err != nil {
+						return nil, err
+					}
+					content = append(content, value)
+					continue
+				}
+				value, err := updateNode(n.Content[i], e)
+				if err != nil {
+					return nil, err
+				}
+				content = append(content, value)
+			}
+			n.Content = content
+			return n, nil
+		}
+	}
+	replaced := &yaml.Node{}
+	if err := replaced.Encode(value); err != nil {
+		return nil, err
+	}
+	replaced.HeadComment = n.HeadComment
+	replaced.LineComment = n.LineComment
+	replaced.FootComment = n.FootComment
+	if replaced.Kind == n.Kind && n.Kind != yaml.ScalarNode {
+		replaced.Style = n.Style
+	}
+	return replaced, nil
+}
+
+// stringMap converts a map of strings to the generic map decoded from YAML.
+func stringMap(m map[string]string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// Test_MarshalDocument tests the MarshalDocument function.
+func Test_MarshalDocument(t *testing.T) {
+	t.Parallel()
+	r, err := New("host", "db-01", "", "platform")
+	assert.NoError(t, err)
+	r.Labels = map[string]string{"env": "prod"}
+	r.Data = map[string]interface{}{"ip": "10.0.0.1", "cpus": 4}
+	data, err := r.MarshalDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, `name: db-01
+owner: platform
+labels:
+  env: prod
+host:
+  cpus: 4
+  ip: 10.0.0.1
+`, string(data))
+	resources, err := Load(bytes.NewReader(data))
+	assert.NoError(t, err)
+	if assert.Len(t, resources, 1) {
+		assert.Equal(t, r.Labels, resources[0].Labels)
+		assert.Equal(t, r.Data, resources[0].Data)
+	}
+}
+
+// Test_MarshalDocument_Preserve tests that the MarshalDocument function
+// keeps the order, comments and anchors of a loaded document.
+func Test_MarshalDocument_Preserve(t *testing.T) {
+	t.Parallel()
+	text := `# The primary database.
+owner: platform # on call
+name: db-01
+host:
+  # Network settings.
+  ip: 10.0.0.1
+  ports: [22, 5432]
+  base: &base
+    os: linux
+  copy: *base
+  zone: a # primary zone
+`
+	resources, err := Load(bytes.NewReader([]byte(text)))
+	assert.NoError(t, err)
+	r := resources[0]
+	data, err := r.MarshalDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, text, string(data))
+
+	assert.NoError(t, r.SetField("data.zone", "b"))
+	assert.NoError(t, r.SetField("data.disk", 100))
+	assert.NoError(t, r.SetField("description", "primary"))
+	r.Labels = map[string]string{"env": "prod"}
+	data, err = r.MarshalDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, `# The primary database.
+owner: platform # on call
+name: db-01
+description: primary
+labels:
+  env: prod
+host:
+  # Network settings.
+  ip: 10.0.0.1
+  ports: [22, 5432]
+  base: &base
+    os: linux
+  copy: *base
+  zone: b # primary zone
+  disk: 100
+`, string(data))
+
+	r.Labels = nil
+	r.Kind = "server"
+	r.Data = "gone"
+	data, err = r.MarshalDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, `# The primary database.
+owner: platform # on call
+name: db-01
+description: primary
+server: gone
+`, string(data))
+}
+
+// failingMarshaler is a value that cannot be marshaled to YAML.
+type failingMarshaler struct{}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (failingMarshaler) MarshalYAML() (interface{}, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+// Test_MarshalYAML_Document tests that marshaling a loaded resource with
+// MarshalYAML keeps its document, and that a failed MarshalDocument leaves
+// it as is.
+func Test_MarshalYAML_Document(t *testing.T) {
+	t.Parallel()
+	text := "name: db-01\nhost:\n  base: &base\n    os: linux\n  copy: *base\n"
+	resources, err := Load(bytes.NewReader([]byte(text)))
+	assert.NoError(t, err)
+	r := resources[0]
+	want, err := yaml.Marshal(r.doc)
+	assert.NoError(t, err)
+	r.Kind = "server"
+	r.Labels = map[string]string{"env": "prod"}
+	data, err := yaml.Marshal(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "name: db-01\nlabels:\n    env: prod\nserver:\n    base: &base\n        os: linux\n    copy: *base\n", string(data))
+	doc, err := yaml.Marshal(r.doc)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(doc))
+
+	r.Data = failingMarshaler{}
+	_, err = r.MarshalDocument()
+	assert.Error(t, err)
+	doc, err = yaml.Marshal(r.doc)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(doc))
+}
+
+// Test_MarshalDocument_Preamble tests that the MarshalDocument function
+// leaves out the comments before the first document separator.
+func Test_MarshalDocument_Preamble(t *testing.T) {
+	t.Parallel()
+	text := "# header\n\n---\n# first\nname: a\nhost: {}\n---\nname: b\nhost: {}\n"
+	resources, err := Load(bytes.NewReader([]byte(text)))
+	assert.NoError(t, err)
+	m := ParseManifest([]byte(text))
+	for _, r := range resources {
+		data, err := r.MarshalDocument()
+		assert.NoError(t, err)
+		assert.NoError(t, m.Replace(r.Index(), string(data)))
+	}
+	assert.Equal(t, text, string(m.Bytes()))
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Position is the position of a document in a manifest.
+type Position struct {
+	// Path is the path to the manifest.
+	Path string
+	// Index is the index of the document within the manifest.
+	Index int
+	// Line is the line of the document, starting at 1, or 0 if unknown.
+	Line int
+	// Column is the column of the document, starting at 1, or 0 if unknown.
+	Column int
+}
+
+// String returns "<path>:<line>:<column>" if the line is known and
+// "<path>[<index>]" otherwise. The column is left out if it is unknown.
+func (p Position) String() string {
+	if p.Line > 0 && p.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d", p.Path, p.Line, p.Column)
+	}
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d", p.Path, p.Line)
+	}
+	return fmt.Sprintf("%s[%d]", p.Path, p.Index)
+}
+
+// LoadError is an error loading a document of a manifest.
+type LoadError struct {
+	Position
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Position, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadErrors is a list of errors loading the documents of a manifest.
+type LoadErrors []*LoadError
+
+// Error implements the error interface.
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors.
+func (e LoadErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// yamlLineRegexp extracts the line from a YAML error message.
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// newYAMLLoadError returns a new load error for a YAML error, taking the line
+// from the error message if it has one.
+func newYAMLLoadError(index int, err error) *LoadError {
+	e := &LoadError{Position: Position{Index: index}, Err: err}
+	if m := yamlLineRegexp.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+	return e
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field returns the value at a dotted path of the resource. The path starts
+// with one of the standard fields (kind, name, description and owner) or with
+// "data", followed by map keys and list indexes into the data, as produced by
+// Flatten. Labels and annotations are looked up with "labels.<key>" and
+// "annotations.<key>", where the key may itself contain dots. It returns
+// false if there is no value at the path.
+func (r *Resource) Field(path string) (interface{}, bool) {
+	elems := strings.Split(path, ".")
+	switch elems[0] {
+	case "kind":
+		return r.Kind, len(elems) == 1
+	case "name":
+		return r.Name, len(elems) == 1
+	case "description":
+		return r.Description, len(elems) == 1
+	case "owner":
+		return r.Owner, len(elems) == 1
+	case "labels":
+		return lookupString(r.Labels, elems[1:])
+	case "annotations":
+		return lookupString(r.Annotations, elems[1:])
+	case "data":
+		return lookup(r.Data, elems[1:])
+	default:
+		return nil, false
+	}
+}
+
+// SetField sets the value at a dotted path of the resource, as accepted by
+// Field. The kind cannot be set. Missing data maps are created along the
+// path, and list elements must already exist.
+func (r *Resource) SetField(path string, value interface{}) error {
+	elems := strings.Split(path, ".")
+	switch {
+	case path == "name":
+		r.Name = fmt.Sprint(value)
+	case path == "description":
+		r.Description = fmt.Sprint(value)
+	case path == "owner":
+		r.Owner = fmt.Sprint(value)
+	case elems[0] == "labels" && len(elems) > 1:
+		if r.Labels == nil {
+			r.Labels = map[string]string{}
+		}
+		r.Labels[strings.Join(elems[1:], ".")] = fmt.Sprint(value)
+	case elems[0] == "annotations" && len(elems) > 1:
+		if r.Annotations == nil {
+			r.Annotations = map[string]string{}
+		}
+		r.Annotations[strings.Join(elems[1:], ".")] = fmt.Sprint(value)
+	case elems[0] == "data":
+		data, err := assign(r.Data, elems[1:], value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrorInvalidField, path, err)
+		}
+		r.Data = data
+	default:
+		return fmt.Errorf("%w: %s", ErrorInvalidField, path)
+	}
+	return nil
+}
+
+// assign returns the container with the value assigned at a path into it.
+func assign(container interface{}, elems []string, value interface{}) (interface{}, error) {
+	if len(elems) == 0 {
+		return value, nil
+	}
+	switch c := container.(type) {
+	case nil:
+		v, err := assign(nil, elems[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{elems[0]: v}, nil
+	case map[string]interface{}:
+		v, err := assign(c[elems[0]], elems[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		c[elems[0]] = v
+		return c, nil
+	case []interface{}:
+		i, err := strconv.Atoi(elems[0])
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, fmt.Errorf("no list element %s", elems[0])
+		}
+		v, err := assign(c[i], elems[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+		return c, nil
+	default:
+		return nil, fmt.Errorf("cannot set %s of a %T", elems[0], container)
+	}
+}
+
+// IsFieldPath returns true if the path starts with one of the fields known
+// to Field. It does not check that the resource has a value at the path.
+func IsFieldPath(path string) bool {
+	switch root, _, _ := strings.Cut(path, "."); root {
+	case "kind", "name", "description", "owner":
+		return root == path
+	case "labels", "annotations", "data":
+		return true
+	default:
+		return false
+	}
+}
+
+// lookupString returns the map, or the value of the key made of the joined
+// elements.
+func lookupString(m map[string]string, elems []string) (interface{}, bool) {
+	if len(elems) == 0 {
+		return m, m != nil
+	}
+	v, ok := m[strings.Join(elems, ".")]
+	return v, ok
+}
+
+// lookup returns the value at a path into the data.
+func lookup(value interface{}, elems []string) (interface{}, bool) {
+	for _, elem := range elems {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[elem]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case map[interface{}]interface{}:
+			found := false
+			for k, next := range v {
+				if fmt.Sprint(k) == elem {
+					value, found = next, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Field tests the Field function.
+func Test_Field(t *testing.T) {
+	t.Parallel()
+	r, err := New("host", "db-01", "database", "platform")
+	assert.NoError(t, err)
+	r.Labels = map[string]string{"env": "prod", "app.kubernetes.io/name": "db"}
+	r.Data = map[string]interface{}{
+		"ip": "10.0.0.1",
+		"os": map[string]interface{}{
+			"version": "6.1",
+		},
+		"disks": []interface{}{
+			map[string]interface{}{"size": 100},
+		},
+		"ports": map[interface{}]interface{}{
+			22: "ssh",
+		},
+	}
+	cases := []struct {
+		path  string
+		value interface{}
+		ok    bool
+	}{
+		{"kind", "host", true},
+		{"name", "db-01", true},
+		{"description", "database", true},
+		{"owner", "platform", true},
+		{"name.x", "db-01", false},
+		{"data.ip", "10.0.0.1", true},
+		{"data.os.version", "6.1", true},
+		{"data.disks.0.size", 100, true},
+		{"data.ports.22", "ssh", true},
+		{"data.ports.80", nil, false},
+		{"data.disks.1", nil, false},
+		{"data.disks.x", nil, false},
+		{"data.ip.x", nil, false},
+		{"data.missing", nil, false},
+		{"labels.env", "prod", true},
+		{"labels.app.kubernetes.io/name", "db", true},
+		{"labels.tier", nil, false},
+		{"labels", r.Labels, true},
+		{"annotations", nil, false},
+		{"annotations.x", nil, false},
+		{"other", nil, false},
+	}
+	for _, c := range cases {
+		value, ok := r.Field(c.path)
+		assert.Equal(t, c.ok, ok, c.path)
+		if c.ok {
+			assert.Equal(t, c.value, value, c.path)
+		}
+	}
+	value, ok := r.Field("data")
+	assert.True(t, ok)
+	assert.Equal(t, r.Data, value)
+}
+
+// Test_IsFieldPath tests the IsFieldPath function.
+func Test_IsFieldPath(t *testing.T) {
+	t.Parallel()
+	for _, path := range []string{"kind", "name", "owner", "labels", "labels.env", "data", "data.os.version"} {
+		assert.True(t, IsFieldPath(path), path)
+	}
+	for _, path := range []string{"", "name.x", "dta.ip", "other"} {
+		assert.False(t, IsFieldPath(path), path)
+	}
+}
+
+// Test_SetField tests the SetField function.
+func Test_SetField(t *testing.T) {
+	t.Parallel()
+	r, err := New("host", "db-01", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, r.SetField("owner", "platform"))
+	assert.NoError(t, r.SetField("labels.app.kubernetes.io/name", "db"))
+	assert.NoError(t, r.SetField("annotations.note", "x"))
+	assert.NoError(t, r.SetField("data.os.version", "6.1"))
+	assert.NoError(t, r.SetField("data.ip", "10.0.0.1"))
+	assert.NoError(t, r.SetField("data.disks", []interface{}{map[string]interface{}{}}))
+	assert.NoError(t, r.SetField("data.disks.0.size", 100))
+	assert.Equal(t, "platform", r.Owner)
+	assert.Equal(t, map[string]string{"app.kubernetes.io/name": "db"}, r.Labels)
+	assert.Equal(t, map[string]string{"note": "x"}, r.Annotations)
+	assert.Equal(t, map[string]interface{}{
+		"os":    map[string]interface{}{"version": "6.1"},
+		"ip":    "10.0.0.1",
+		"disks": []interface{}{map[string]interface{}{"size": 100}},
+	}, r.Data)
+	for _, path := range []string{"kind", "labels", "other", "data.ip.x", "data.disks.1", "data.disks.x"} {
+		assert.ErrorIs(t, r.SetField(path, "v"), ErrorInvalidField, path)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Field is a flattened resource field.
+type Field struct {
+	// Key is the dotted path to the field.
+	Key string
+	// Value is the value of the field.
+	Value interface{}
+}
+
+// Flatten flattens the resource into dotted key/value fields. The standard
+// fields come first, followed by the labels and annotations, and then the
+// data fields under the "data" prefix.
+// Map keys are sorted and list elements are keyed by their index.
+func (r *Resource) Flatten() []Field {
+	fields := []Field{
+		{Key: "kind", Value: r.Kind},
+		{Key: "name", Value: r.Name},
+		{Key: "description", Value: r.Description},
+		{Key: "owner", Value: r.Owner},
+	}
+	fields = flattenStrings(fields, "labels", r.Labels)
+	fields = flattenStrings(fields, "annotations", r.Annotations)
+	return flatten(fields, "data", r.Data)
+}
+
+// flattenStrings appends the entries of a map of strings to fields, sorted by
+// key.
+func flattenStrings(fields []Field, prefix string, m map[string]string) []Field {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, Field{Key: prefix + "." + k, Value: m[k]})
+	}
+	return fields
+}
+
+// flatten appends the flattened value to fields.
+func flatten(fields []Field, prefix string, value interface{}) []Field {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return append(fields, Field{Key: prefix, Value: "{}"})
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fields = flatten(fields, prefix+"."+k, v[k])
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = e
+		}
+		return flatten(fields, prefix, m)
+	case []interface{}:
+		if len(v) == 0 {
+			return append(fields, Field{Key: prefix, Value: "[]"})
+		}
+		for i, e := range v {
+			fields = flatten(fields, prefix+"."+strconv.Itoa(i), e)
+		}
+	default:
+		fields = append(fields, Field{Key: prefix, Value: value})
+	}
+	return fields
+}
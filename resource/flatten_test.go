@@ -0,0 +1,83 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Flatten tests the Flatten function.
+func Test_Flatten(t *testing.T) {
+	t.Parallel()
+	r, err := New("kind", "name", "description", "owner")
+	assert.NoError(t, err)
+	r.Data = map[string]interface{}{
+		"b": "value",
+		"a": map[string]interface{}{
+			"list":  []interface{}{"x", map[string]interface{}{"y": 1}},
+			"empty": []interface{}{},
+		},
+		"c": map[interface{}]interface{}{
+			1: true,
+		},
+		"d": map[string]interface{}{},
+	}
+	assert.Equal(t, []Field{
+		{Key: "kind", Value: "kind"},
+		{Key: "name", Value: "name"},
+		{Key: "description", Value: "description"},
+		{Key: "owner", Value: "owner"},
+		{Key: "data.a.empty", Value: "[]"},
+		{Key: "data.a.list.0", Value: "x"},
+		{Key: "data.a.list.1.y", Value: 1},
+		{Key: "data.b", Value: "value"},
+		{Key: "data.c.1", Value: true},
+		{Key: "data.d", Value: "{}"},
+	}, r.Flatten())
+}
+
+// Test_Flatten_Scalar tests the Flatten function with scalar data.
+func Test_Flatten_Scalar(t *testing.T) {
+	t.Parallel()
+	r, err := New("kind", "name", "description", "owner")
+	assert.NoError(t, err)
+	r.Data = "test"
+	fields := r.Flatten()
+	assert.Len(t, fields, 5)
+	assert.Equal(t, Field{Key: "data", Value: "test"}, fields[4])
+}
+
+// Test_Flatten_Labels tests that the Flatten function emits the labels and
+// annotations after the standard fields.
+func Test_Flatten_Labels(t *testing.T) {
+	t.Parallel()
+	r, err := New("kind", "name", "", "")
+	assert.NoError(t, err)
+	r.Labels = map[string]string{"tier": "db", "env": "prod"}
+	r.Annotations = map[string]string{"note": "x"}
+	r.Data = 1
+	assert.Equal(t, []Field{
+		{Key: "kind", Value: "kind"},
+		{Key: "name", Value: "name"},
+		{Key: "description", Value: ""},
+		{Key: "owner", Value: ""},
+		{Key: "labels.env", Value: "prod"},
+		{Key: "labels.tier", Value: "db"},
+		{Key: "annotations.note", Value: "x"},
+		{Key: "data", Value: 1},
+	}, r.Flatten())
+}
@@ -15,42 +15,108 @@
 package resource
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadFile loads a manifest.
+// LoadFile loads a manifest. See Load for how errors are reported.
 func LoadFile(path string) ([]*Resource, error) {
 	inf, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, LoadErrors{{Position: Position{Path: path}, Err: err}}
 	}
 	defer inf.Close()
 	resources, err := Load(inf)
-	if err != nil {
-		return nil, err
-	}
 	for _, r := range resources {
 		r.loadedFrom = path
 	}
-	return resources, nil
+	if errs, ok := err.(LoadErrors); ok {
+		for _, e := range errs {
+			e.Path = path
+		}
+	}
+	return resources, err
 }
 
-// Load loads a manifest from a reader.
+// Load loads a manifest from a reader. Documents that cannot be decoded are
+// skipped, and the resources of the other documents are returned along with
+// a LoadErrors. A syntax error ends the manifest, since the documents that
+// follow it cannot be located.
 func Load(r io.Reader) ([]*Resource, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, LoadErrors{{Err: err}}
+	}
+	preamble := ParseManifest(data).preamble
 	resources := []*Resource{}
-	dec := yaml.NewDecoder(r)
-	for {
-		r := &Resource{}
-		if err := dec.Decode(r); err != nil {
-			if err == io.EOF {
-				break
+	errs := LoadErrors{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for index := 0; ; index++ {
+		doc := &yaml.Node{}
+		if err := dec.Decode(doc); err != nil {
+			if err != io.EOF {
+				errs = append(errs, newYAMLLoadError(index, err))
+			}
+			break
+		}
+		if index == 0 {
+			stripPreamble(doc, preamble)
+		}
+		r := &Resource{index: index}
+		if len(doc.Content) > 0 {
+			r.line, r.column = doc.Content[0].Line, doc.Content[0].Column
+		}
+		if err := doc.Decode(r); err != nil {
+			e := newYAMLLoadError(index, err)
+			if e.Line == 0 || e.Line == r.line {
+				e.Line, e.Column = r.line, r.column
 			}
-			return nil, err
+			errs = append(errs, e)
+			continue
 		}
+		r.doc = doc
 		resources = append(resources, r)
 	}
+	if len(errs) > 0 {
+		return resources, errs
+	}
 	return resources, nil
 }
+
+// stripPreamble removes the comments of the manifest preamble from the head
+// comments of the first document, to which the YAML decoder attaches them.
+// This keeps the preamble out of the document when it is written back.
+func stripPreamble(doc *yaml.Node, preamble string) {
+	lines := []string{}
+	for _, line := range strings.Split(preamble, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	targets := []*yaml.Node{doc}
+	if len(doc.Content) > 0 {
+		targets = append(targets, doc.Content[0])
+		if len(doc.Content[0].Content) > 0 {
+			targets = append(targets, doc.Content[0].Content[0])
+		}
+	}
+	for _, n := range targets {
+		if len(lines) == 0 || n.HeadComment == "" {
+			continue
+		}
+		comment := strings.Split(n.HeadComment, "\n")
+		for len(lines) > 0 && len(comment) > 0 {
+			if strings.TrimSpace(comment[0]) == lines[0] {
+				lines = lines[1:]
+			} else if strings.TrimSpace(comment[0]) != "" {
+				break
+			}
+			comment = comment[1:]
+		}
+		n.HeadComment = strings.TrimLeft(strings.Join(comment, "\n"), "\n")
+	}
+}
@@ -15,6 +15,7 @@
 package resource
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -49,6 +50,10 @@ func Test_Load_Multiple(t *testing.T) {
 	assert.Equal(t, "owner2", resources[1].Owner)
 	assert.Equal(t, "resource2", resources[1].Kind)
 	assert.Equal(t, "test2", resources[1].Data.(string))
+	for i, r := range resources {
+		assert.Equal(t, "testdata/multiple.yaml", r.LoadedFrom())
+		assert.Equal(t, i, r.Index())
+	}
 	assert.Equal(t, "name3", resources[2].Name)
 	assert.Equal(t, "description3", resources[2].Description)
 	assert.Equal(t, "owner3", resources[2].Owner)
@@ -66,6 +71,48 @@ func Test_Load_Corrupted(t *testing.T) {
 	t.Parallel()
 	_, err := LoadFile("testdata/corrupted.yaml")
 	assert.Error(t, err)
+	var errs LoadErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, Position{Path: "testdata/corrupted.yaml", Index: 0, Line: 15, Column: 1}, errs[0].Position)
+}
+
+// Test_Load_Partial tests loading a manifest with an invalid document in the
+// middle.
+func Test_Load_Partial(t *testing.T) {
+	t.Parallel()
+	resources, err := LoadFile("testdata/partial.yaml")
+	assert.Len(t, resources, 2)
+	assert.Equal(t, "name", resources[0].Name)
+	assert.Equal(t, Position{Path: "testdata/partial.yaml", Index: 0, Line: 15, Column: 1}, resources[0].Position())
+	assert.Equal(t, "name3", resources[1].Name)
+	assert.Equal(t, 2, resources[1].Index())
+	var errs LoadErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, Position{Path: "testdata/partial.yaml", Index: 1, Line: 18, Column: 1}, errs[0].Position)
+	assert.EqualError(t, err, "testdata/partial.yaml:18:1: resource has more than one kind")
+}
+
+// Test_Load_Syntax tests loading a manifest with a syntax error.
+func Test_Load_Syntax(t *testing.T) {
+	t.Parallel()
+	resources, err := LoadFile("testdata/syntax.yaml")
+	assert.Len(t, resources, 1)
+	var errs LoadErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 1, errs[0].Index)
+	assert.Equal(t, 19, errs[0].Line)
+	assert.Len(t, errs.Unwrap(), 1)
+}
+
+// Test_Position_String tests the Position String function.
+func Test_Position_String(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "a.yaml:3:1", Position{Path: "a.yaml", Index: 1, Line: 3, Column: 1}.String())
+	assert.Equal(t, "a.yaml:3", Position{Path: "a.yaml", Index: 1, Line: 3}.String())
+	assert.Equal(t, "a.yaml[1]", Position{Path: "a.yaml", Index: 1}.String())
 }
 
 // Test_Load_Missing tests loading a resource with missing fields.
@@ -91,5 +138,7 @@ func Test_Load_Empty(t *testing.T) {
 func Test_Load_Nonexistent(t *testing.T) {
 	t.Parallel()
 	_, err := LoadFile("testdata/nonexistent.yaml")
-	assert.Error(t, err)
+	var errs LoadErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.True(t, os.IsNotExist(errs[0].Err))
 }
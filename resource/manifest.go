@@ -0,0 +1,219 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ErrorDocumentNotFound is the error returned when a manifest has no
+	// document at an index.
+	ErrorDocumentNotFound = Error("document not found")
+)
+
+// Manifest is the text of a multi-document YAML manifest, split into its
+// documents so that one document can be replaced or deleted without
+// disturbing the text of the others. Documents are indexed as by Load.
+type Manifest struct {
+	// preamble is the text before the first document, such as a comment
+	// header.
+	preamble string
+	// docs are the texts of the documents. Each document but the first
+	// starts with its "---" separator line.
+	docs []string
+}
+
+// ParseManifest splits the text of a manifest into documents. A document
+// starts at each "---" separator line. The text before the first separator is
+// a document only if it holds more than comments and blank lines.
+func ParseManifest(data []byte) *Manifest {
+	m := &Manifest{}
+	chunks := []string{}
+	start := 0
+	text := string(data)
+	for i := 0; i < len(text); {
+		end := strings.IndexByte(text[i:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += i + 1
+		}
+		if i > 0 && isSeparator(text[i:end]) {
+			chunks = append(chunks, text[start:i])
+			start = i
+		}
+		i = end
+	}
+	chunks = append(chunks, text[start:])
+	if !isSeparator(chunks[0]) && !hasContent(chunks[0]) {
+		m.preamble = chunks[0]
+		chunks = chunks[1:]
+	}
+	m.docs = chunks
+	return m
+}
+
+// ReadManifest reads a manifest. A missing file yields an empty manifest.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Manifest{}, nil
+		}
+		return nil, err
+	}
+	return ParseManifest(data), nil
+}
+
+// Len returns the number of documents of the manifest.
+func (m *Manifest) Len() int {
+	return len(m.docs)
+}
+
+// Document returns the text of a document, without its separator line.
+func (m *Manifest) Document(index int) (string, error) {
+	if index < 0 || index >= len(m.docs) {
+		return "", fmt.Errorf("%w: %d", ErrorDocumentNotFound, index)
+	}
+	_, body := splitSeparator(m.docs[index])
+	return body, nil
+}
+
+// Replace replaces the text of a document, keeping its separator line.
+func (m *Manifest) Replace(index int, doc string) error {
+	if index < 0 || index >= len(m.docs) {
+		return fmt.Errorf("%w: %d", ErrorDocumentNotFound, index)
+	}
+	sep, _ := splitSeparator(m.docs[index])
+	m.docs[index] = sep + withNewline(doc)
+	return nil
+}
+
+// Delete deletes a document along with its separator line.
+func (m *Manifest) Delete(index int) error {
+	if index < 0 || index >= len(m.docs) {
+		return fmt.Errorf("%w: %d", ErrorDocumentNotFound, index)
+	}
+	m.docs = append(m.docs[:index], m.docs[index+1:]...)
+	return nil
+}
+
+// Append appends a document and returns its index.
+func (m *Manifest) Append(doc string) int {
+	doc = withNewline(doc)
+	if last := len(m.docs) - 1; last >= 0 {
+		m.docs[last] = withNewline(m.docs[last])
+		doc = "---\n" + doc
+	} else if strings.TrimSpace(m.preamble) == "" {
+		m.preamble = ""
+	} else {
+		m.preamble = withNewline(m.preamble)
+	}
+	m.docs = append(m.docs, doc)
+	return len(m.docs) - 1
+}
+
+// Bytes returns the text of the manifest.
+func (m *Manifest) Bytes() []byte {
+	return []byte(m.preamble + strings.Join(m.docs, ""))
+}
+
+// IsEmpty returns true if the manifest has neither documents nor any text
+// besides comments and blank lines.
+func (m *Manifest) IsEmpty() bool {
+	return len(m.docs) == 0 && !hasContent(m.preamble)
+}
+
+// WriteFile writes the manifest to a file. The file is replaced atomically,
+// keeping its permissions, and missing directories are created.
+func (m *Manifest) WriteFile(path string) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(m.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// isSeparator returns true if the text starts with a "---" document
+// separator line.
+func isSeparator(text string) bool {
+	if !strings.HasPrefix(text, "---") {
+		return false
+	}
+	rest := text[3:]
+	return rest == "" || rest[0] == '\n' || rest[0] == '\r' || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// splitSeparator splits the separator line, if any, from the text of a
+// document. A separator line holding more than the separator is kept with
+// the body.
+func splitSeparator(doc string) (string, string) {
+	if !isSeparator(doc) {
+		return "", doc
+	}
+	end := strings.IndexByte(doc, '\n') + 1
+	if end == 0 {
+		end = len(doc)
+	}
+	if line := strings.TrimSpace(doc[:end]); line != "---" && !strings.HasPrefix(line, "--- #") {
+		return "", doc
+	}
+	return doc[:end], doc[end:]
+}
+
+// hasContent returns true if the text holds more than comments, directives
+// and blank lines.
+func hasContent(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "%") {
+			return true
+		}
+	}
+	return false
+}
+
+// withNewline returns the text ending with a newline, unless it is empty.
+func withNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
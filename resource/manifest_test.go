@@ -0,0 +1,115 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_ParseManifest tests that the ParseManifest function counts documents
+// as the Load function does.
+func Test_ParseManifest(t *testing.T) {
+	t.Parallel()
+	for _, text := range []string{
+		"",
+		"# only a comment\n",
+		"name: a\nhost: {}\n",
+		"# header\n---\nname: a\nhost: {}\n",
+		"---\nname: a\nhost: {}\n---\nname: b\nhost: {}",
+		"name: a\nhost: {}\n--- # second\nname: b\nhost: {}\n---\nname: c\nhost: {}\n",
+		"name: a\nhost: |\n  ---not a separator\n",
+	} {
+		resources, _ := Load(bytes.NewReader([]byte(text)))
+		m := ParseManifest([]byte(text))
+		assert.Equal(t, len(resources), m.Len(), text)
+		assert.Equal(t, text, string(m.Bytes()), text)
+	}
+	data, err := os.ReadFile("testdata/multiple.yaml")
+	assert.NoError(t, err)
+	m := ParseManifest(data)
+	assert.Equal(t, 3, m.Len())
+	doc, err := m.Document(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "name: name2\ndescription: description2\nowner: owner2\nresource2: test2\n", doc)
+	_, err = m.Document(3)
+	assert.ErrorIs(t, err, ErrorDocumentNotFound)
+}
+
+// Test_Manifest_Replace tests the Manifest Replace function.
+func Test_Manifest_Replace(t *testing.T) {
+	t.Parallel()
+	m := ParseManifest([]byte("# a\nname: a\nhost: {}\n--- # b\nname: b\nhost: {}\n---\nname: c\nhost: {}\n"))
+	assert.NoError(t, m.Replace(1, "name: b\nhost: {ip: 1}"))
+	assert.NoError(t, m.Replace(0, "name: a\nhost: {ip: 2}\n"))
+	assert.Equal(t, "name: a\nhost: {ip: 2}\n--- # b\nname: b\nhost: {ip: 1}\n---\nname: c\nhost: {}\n", string(m.Bytes()))
+	assert.ErrorIs(t, m.Replace(-1, ""), ErrorDocumentNotFound)
+}
+
+// Test_Manifest_Delete tests the Manifest Delete function.
+func Test_Manifest_Delete(t *testing.T) {
+	t.Parallel()
+	m := ParseManifest([]byte("# header\n---\nname: a\nhost: {}\n---\nname: b\nhost: {}\n---\nname: c\nhost: {}\n"))
+	assert.NoError(t, m.Delete(1))
+	assert.Equal(t, "# header\n---\nname: a\nhost: {}\n---\nname: c\nhost: {}\n", string(m.Bytes()))
+	assert.NoError(t, m.Delete(0))
+	assert.Equal(t, "# header\n---\nname: c\nhost: {}\n", string(m.Bytes()))
+	assert.NoError(t, m.Delete(0))
+	assert.True(t, m.IsEmpty())
+	assert.ErrorIs(t, m.Delete(0), ErrorDocumentNotFound)
+}
+
+// Test_Manifest_Append tests the Manifest Append function.
+func Test_Manifest_Append(t *testing.T) {
+	t.Parallel()
+	m := ParseManifest(nil)
+	assert.Equal(t, 0, m.Append("name: a\nhost: {}"))
+	assert.Equal(t, 1, m.Append("name: b\nhost: {}\n"))
+	assert.Equal(t, "name: a\nhost: {}\n---\nname: b\nhost: {}\n", string(m.Bytes()))
+
+	m = ParseManifest([]byte("# header"))
+	m.Append("name: a\nhost: {}\n")
+	assert.Equal(t, "# header\nname: a\nhost: {}\n", string(m.Bytes()))
+	resources, err := Load(bytes.NewReader(m.Bytes()))
+	assert.NoError(t, err)
+	assert.Len(t, resources, 1)
+}
+
+// Test_Manifest_WriteFile tests the Manifest WriteFile function.
+func Test_Manifest_WriteFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "host", "db.yaml")
+	m, err := ReadManifest(path)
+	assert.NoError(t, err)
+	assert.True(t, m.IsEmpty())
+	m.Append("name: a\nhost: {}\n")
+	assert.NoError(t, m.WriteFile(path))
+	assert.NoError(t, os.Chmod(path, 0600))
+	m.Append("name: b\nhost: {}\n")
+	assert.NoError(t, m.WriteFile(path))
+	resources, err := LoadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+// Merge merges an overlay of the same kind and name into the resource. The
+// non-empty description and owner of the overlay replace those of the
+// resource, and its labels and annotations are added to those of the
+// resource, replacing existing keys. Data maps are merged recursively, and any other overlay data
+// replaces the data of the resource.
+func (r *Resource) Merge(overlay *Resource) {
+	if overlay.Description != "" {
+		r.Description = overlay.Description
+	}
+	if overlay.Owner != "" {
+		r.Owner = overlay.Owner
+	}
+	r.Labels = mergeStrings(r.Labels, overlay.Labels)
+	r.Annotations = mergeStrings(r.Annotations, overlay.Annotations)
+	r.Data = mergeData(r.Data, overlay.Data)
+}
+
+// mergeStrings merges overlay entries into base entries.
+func mergeStrings(base, overlay map[string]string) map[string]string {
+	if len(overlay) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeData merges overlay data into base data.
+func mergeData(base, overlay interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	overlayMap, ok := overlay.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	merged := make(map[string]interface{}, len(baseMap)+len(overlayMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overlayMap {
+		merged[k] = mergeData(merged[k], v)
+	}
+	return merged
+}
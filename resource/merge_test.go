@@ -0,0 +1,73 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Merge tests the Merge function.
+func Test_Merge(t *testing.T) {
+	t.Parallel()
+	base, err := New("host", "db-01", "database", "platform")
+	assert.NoError(t, err)
+	base.Data = map[string]interface{}{
+		"ip": "10.0.0.1",
+		"os": map[string]interface{}{
+			"name":    "linux",
+			"version": "5.10",
+		},
+		"tags": []interface{}{"a"},
+	}
+	overlay, err := New("host", "db-01", "", "dba")
+	assert.NoError(t, err)
+	overlay.Data = map[string]interface{}{
+		"os": map[string]interface{}{
+			"version": "6.1",
+		},
+		"tags": []interface{}{"b"},
+	}
+	base.Merge(overlay)
+	assert.Equal(t, "database", base.Description)
+	assert.Equal(t, "dba", base.Owner)
+	assert.Equal(t, map[string]interface{}{
+		"ip": "10.0.0.1",
+		"os": map[string]interface{}{
+			"name":    "linux",
+			"version": "6.1",
+		},
+		"tags": []interface{}{"b"},
+	}, base.Data)
+}
+
+// Test_Merge_Scalar tests the Merge function with scalar data.
+func Test_Merge_Scalar(t *testing.T) {
+	t.Parallel()
+	base := &Resource{Kind: "k", Name: "n", Data: map[string]interface{}{"a": 1}}
+	base.Merge(&Resource{Kind: "k", Name: "n", Data: "b"})
+	assert.Equal(t, "b", base.Data)
+}
+
+// Test_Merge_Labels tests that the Merge function merges labels and
+// annotations.
+func Test_Merge_Labels(t *testing.T) {
+	t.Parallel()
+	base := &Resource{Kind: "k", Name: "n", Labels: map[string]string{"env": "dev", "tier": "db"}}
+	base.Merge(&Resource{Kind: "k", Name: "n", Labels: map[string]string{"env": "prod"}, Annotations: map[string]string{"a": "b"}})
+	assert.Equal(t, map[string]string{"env": "prod", "tier": "db"}, base.Labels)
+	assert.Equal(t, map[string]string{"a": "b"}, base.Annotations)
+}
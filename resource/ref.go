@@ -0,0 +1,123 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ErrorInvalidRef is the error returned when a reference is malformed.
+	ErrorInvalidRef = Error("invalid reference")
+)
+
+// RefKey is the key of a reference in the data of a resource. A reference is
+// a map holding only this key, whose value is "<kind>/<name>":
+//
+//	database:
+//	  ref: host/db-01
+const RefKey = "ref"
+
+// Ref identifies a resource by kind and name.
+type Ref struct {
+	// Kind is the kind of the resource.
+	Kind string `json:"kind" yaml:"kind"`
+	// Name is the name of the resource.
+	Name string `json:"name" yaml:"name"`
+}
+
+// ParseRef parses a "<kind>/<name>" reference.
+func ParseRef(s string) (Ref, error) {
+	kind, name, ok := strings.Cut(s, "/")
+	if !ok || kind == "" || name == "" {
+		return Ref{}, fmt.Errorf("%w: %q", ErrorInvalidRef, s)
+	}
+	return Ref{Kind: kind, Name: name}, nil
+}
+
+// String returns the "<kind>/<name>" form of the reference.
+func (ref Ref) String() string {
+	return ref.Kind + "/" + ref.Name
+}
+
+// Less returns true if the reference sorts before the other reference.
+func (ref Ref) Less(other Ref) bool {
+	if ref.Kind != other.Kind {
+		return ref.Kind < other.Kind
+	}
+	return ref.Name < other.Name
+}
+
+// SortRefs sorts references by kind and name.
+func SortRefs(refs []Ref) {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Less(refs[j])
+	})
+}
+
+// Reference is a reference found in the data of a resource.
+type Reference struct {
+	// Path is the dotted path to the reference within the resource.
+	Path string
+	// Ref is the referenced resource.
+	Ref Ref
+}
+
+// Ref returns the reference to the resource.
+func (r *Resource) Ref() Ref {
+	return Ref{Kind: r.Kind, Name: r.Name}
+}
+
+// References returns the references found in the data of the resource, in
+// the order they appear in the flattened resource.
+func (r *Resource) References() ([]Reference, error) {
+	return findReferences(nil, "data", r.Data)
+}
+
+// findReferences appends the references found in the value to refs.
+func findReferences(refs []Reference, path string, value interface{}) ([]Reference, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if target, ok := v[RefKey].(string); ok && len(v) == 1 {
+			ref, err := ParseRef(target)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return append(refs, Reference{Path: path, Ref: ref}), nil
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var err error
+		for _, k := range keys {
+			if refs, err = findReferences(refs, path+"."+k, v[k]); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		var err error
+		for i, e := range v {
+			if refs, err = findReferences(refs, path+"."+strconv.Itoa(i), e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return refs, nil
+}
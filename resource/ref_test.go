@@ -0,0 +1,81 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_ParseRef tests the ParseRef function.
+func Test_ParseRef(t *testing.T) {
+	t.Parallel()
+	ref, err := ParseRef("host/db-01")
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Kind: "host", Name: "db-01"}, ref)
+	assert.Equal(t, "host/db-01", ref.String())
+	ref, err = ParseRef("path/with/slashes")
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Kind: "path", Name: "with/slashes"}, ref)
+	for _, s := range []string{"", "host", "host/", "/db-01"} {
+		_, err := ParseRef(s)
+		assert.ErrorIs(t, err, ErrorInvalidRef, s)
+	}
+}
+
+// Test_SortRefs tests the SortRefs function.
+func Test_SortRefs(t *testing.T) {
+	t.Parallel()
+	refs := []Ref{{"b", "a"}, {"a", "b"}, {"a", "a"}}
+	SortRefs(refs)
+	assert.Equal(t, []Ref{{"a", "a"}, {"a", "b"}, {"b", "a"}}, refs)
+}
+
+// Test_Resource_References tests the References function.
+func Test_Resource_References(t *testing.T) {
+	t.Parallel()
+	r, err := New("service", "api", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Kind: "service", Name: "api"}, r.Ref())
+	r.Data = map[string]interface{}{
+		"database": map[string]interface{}{"ref": "host/db-01"},
+		"hosts": []interface{}{
+			map[string]interface{}{"ref": "host/web-01"},
+			map[string]interface{}{"ref": "host/web-02", "weight": 1},
+		},
+		"ref": "not/a-reference",
+	}
+	refs, err := r.References()
+	assert.NoError(t, err)
+	assert.Equal(t, []Reference{
+		{Path: "data.database", Ref: Ref{Kind: "host", Name: "db-01"}},
+		{Path: "data.hosts.0", Ref: Ref{Kind: "host", Name: "web-01"}},
+	}, refs)
+}
+
+// Test_Resource_References_Invalid tests the References function with an
+// invalid reference.
+func Test_Resource_References_Invalid(t *testing.T) {
+	t.Parallel()
+	r, err := New("service", "api", "", "")
+	assert.NoError(t, err)
+	r.Data = map[string]interface{}{
+		"database": map[string]interface{}{"ref": "db-01"},
+	}
+	_, err = r.References()
+	assert.ErrorIs(t, err, ErrorInvalidRef)
+	assert.ErrorContains(t, err, "data.database")
+}
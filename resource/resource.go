@@ -17,6 +17,9 @@ package resource
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Error string
@@ -28,8 +31,16 @@ func (e Error) Error() string {
 const (
 	// ErrorKindIsReservedWord is the error returned when a resource kind is a reserved word.
 	ErrorKindIsReservedWord = Error("resource kind is a reserved word")
+	// ErrorInvalidMetadata is the error returned when the labels or
+	// annotations of a resource are not a map of strings.
+	ErrorInvalidMetadata = Error("invalid metadata")
+	// ErrorInvalidField is the error returned when a field cannot be set.
+	ErrorInvalidField = Error("invalid field")
 )
 
+// reservedWords are the top-level keys of a resource that are not kinds.
+var reservedWords = []string{"name", "description", "owner", "labels", "annotations"}
+
 // Resource is a resource.
 type Resource struct {
 	// Kind is the kind of the resource.
@@ -40,10 +51,24 @@ type Resource struct {
 	Description string `json:"description" yaml:"description"`
 	// Owner is the owner of the resource.
 	Owner string `json:"owner" yaml:"owner"`
+	// Labels are identifying key/value pairs used to select resources.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Annotations are non-identifying key/value pairs, such as links or
+	// notes for tooling.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 	// Data is the data of the resource.
 	Data interface{} `json:"-" yaml:"-"`
 	// loadedFrom is the path to the file the resource was loaded from.
 	loadedFrom string
+	// index is the index of the document the resource was loaded from.
+	index int
+	// line is the line of the document the resource was loaded from.
+	line int
+	// column is the column of the document the resource was loaded from.
+	column int
+	// doc is the YAML node of the document the resource was loaded from,
+	// used to write the resource back. See MarshalDocument.
+	doc *yaml.Node
 }
 
 // New returns a new resource.
@@ -59,14 +84,34 @@ func New(kind, name, description, owner string) (*Resource, error) {
 	}, nil
 }
 
-// MarshalYAML implements the yaml.Marshaler interface.
+// LoadedFrom returns the path to the file the resource was loaded from, or an
+// empty string if the resource was not loaded from a file.
+func (r *Resource) LoadedFrom() string {
+	return r.loadedFrom
+}
+
+// Index returns the index of the document the resource was loaded from.
+func (r *Resource) Index() int {
+	return r.index
+}
+
+// Position returns the position of the document the resource was loaded
+// from.
+func (r *Resource) Position() Position {
+	return Position{
+		Path:   r.loadedFrom,
+		Index:  r.index,
+		Line:   r.line,
+		Column: r.column,
+	}
+}
+
+// MarshalYAML implements the yaml.Marshaler interface. The standard fields
+// come first, and a resource loaded from a manifest keeps the order and
+// comments of its document. See MarshalDocument. The resource is left as
+// is.
 func (r *Resource) MarshalYAML() (interface{}, error) {
-	return map[string]interface{}{
-		"name":        r.Name,
-		"description": r.Description,
-		"owner":       r.Owner,
-		r.Kind:        r.Data,
-	}, nil
+	return r.node(copyNode(r.doc))
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -75,54 +120,64 @@ func (r *Resource) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&data); err != nil {
 		return err
 	}
-	r.Name = getField(data, "name")
-	r.Description = getField(data, "description")
-	r.Owner = getField(data, "owner")
-	delete(data, "name")
-	delete(data, "description")
-	delete(data, "owner")
-	for kind, value := range data {
-		// No need to check for reserved words here because all reserved words
-		// are already deleted from the data map.
-		r.Kind = kind
-		r.Data = value
-	}
-	if len(data) != 1 {
-		return fmt.Errorf("resource has more than one kind")
-	}
-	return nil
+	return r.fromMap(data)
 }
 
 // MarshalJSON implements the json.Marshaler interface.
 func (r *Resource) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toMap())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var dataMap map[string]interface{}
+	if err := json.Unmarshal(data, &dataMap); err != nil {
+		return err
+	}
+	return r.fromMap(dataMap)
+}
+
+// toMap returns the JSON form of the resource. Empty labels and annotations
+// are left out.
+func (r *Resource) toMap() map[string]interface{} {
 	data := map[string]interface{}{
 		"name":        r.Name,
 		"description": r.Description,
 		"owner":       r.Owner,
 		r.Kind:        r.Data,
 	}
-	return json.Marshal(data)
+	if len(r.Labels) > 0 {
+		data["labels"] = r.Labels
+	}
+	if len(r.Annotations) > 0 {
+		data["annotations"] = r.Annotations
+	}
+	return data
 }
 
-// UnmarshalJSON implements the json.Unmarshaler interface.
-func (r *Resource) UnmarshalJSON(data []byte) error {
-	var dataMap map[string]interface{}
-	if err := json.Unmarshal(data, &dataMap); err != nil {
+// fromMap sets the resource from its serialized form. The data map is
+// modified.
+func (r *Resource) fromMap(data map[string]interface{}) error {
+	var err error
+	r.Name = getField(data, "name")
+	r.Description = getField(data, "description")
+	r.Owner = getField(data, "owner")
+	if r.Labels, err = getStringMap(data, "labels"); err != nil {
+		return err
+	}
+	if r.Annotations, err = getStringMap(data, "annotations"); err != nil {
 		return err
 	}
-	r.Name = getField(dataMap, "name")
-	r.Description = getField(dataMap, "description")
-	r.Owner = getField(dataMap, "owner")
-	delete(dataMap, "name")
-	delete(dataMap, "description")
-	delete(dataMap, "owner")
-	for kind, value := range dataMap {
+	for _, word := range reservedWords {
+		delete(data, word)
+	}
+	for kind, value := range data {
 		// No need to check for reserved words here because all reserved words
 		// are already deleted from the data map.
 		r.Kind = kind
 		r.Data = value
 	}
-	if len(dataMap) != 1 {
+	if len(data) != 1 {
 		return fmt.Errorf("resource has more than one kind")
 	}
 	return nil
@@ -130,15 +185,63 @@ func (r *Resource) UnmarshalJSON(data []byte) error {
 
 // KindIsReservedWord returns true if the kind is a reserved word.
 func KindIsReservedWord(kind string) bool {
-	return kind == "name" || kind == "description" || kind == "owner"
+	for _, word := range reservedWords {
+		if kind == word {
+			return true
+		}
+	}
+	return false
 }
 
 // getField gets a field from the resource, returning empty string if the field
-// is not present.
+// is not present. Non-string values, such as numeric names, are formatted.
 func getField(data map[string]interface{}, field string) string {
 	value, ok := data[field]
-	if !ok {
+	if !ok || value == nil {
 		return ""
 	}
-	return value.(string)
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// getStringMap gets a map of strings from the resource, returning nil if the
+// field is not present. Scalar values are formatted, and nested maps and lists
+// are rejected.
+func getStringMap(data map[string]interface{}, field string) (map[string]string, error) {
+	value, ok := data[field]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	m := map[string]interface{}{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m = v
+	case map[interface{}]interface{}:
+		for k, e := range v {
+			m[fmt.Sprint(k)] = e
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s: expected a map", ErrorInvalidMetadata, field)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make(map[string]string, len(m))
+	for _, k := range keys {
+		switch e := m[k].(type) {
+		case nil:
+			result[k] = ""
+		case string:
+			result[k] = e
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("%w: %s.%s: expected a string", ErrorInvalidMetadata, field, k)
+		default:
+			result[k] = fmt.Sprint(e)
+		}
+	}
+	return result, nil
 }
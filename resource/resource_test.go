@@ -36,6 +36,8 @@ func Test_New_InvalidKind(t *testing.T) {
 		"name",
 		"description",
 		"owner",
+		"labels",
+		"annotations",
 	}
 	for _, kind := range reserved {
 		_, err := New(kind, "name", "description", "owner")
@@ -51,8 +53,16 @@ func Test_MarshalYAML(t *testing.T) {
 	r.Data = map[string]interface{}{
 		"key": "value",
 	}
-	data, err := r.MarshalYAML()
+	data, err := yaml.Marshal(r)
 	assert.NoError(t, err)
+	assert.Equal(t, `name: name
+description: description
+owner: owner
+kind:
+    key: value
+`, string(data))
+	var m map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(data, &m))
 	assert.Equal(t, map[string]interface{}{
 		"name":        "name",
 		"description": "description",
@@ -60,7 +70,7 @@ func Test_MarshalYAML(t *testing.T) {
 		"kind": map[string]interface{}{
 			"key": "value",
 		},
-	}, data)
+	}, m)
 }
 
 // Test_UnmarshalYAML tests the UnmarshalYAML function.
@@ -260,3 +270,81 @@ func Test_UnmarshalJSON_DuplicateKey(t *testing.T) {
 	}, r)
 
 }
+
+// Test_Labels_RoundTrip tests that labels and annotations round-trip through
+// YAML and JSON.
+func Test_Labels_RoundTrip(t *testing.T) {
+	t.Parallel()
+	r, err := New("host", "db-01", "database", "platform")
+	assert.NoError(t, err)
+	r.Labels = map[string]string{"env": "prod", "tier": "db"}
+	r.Annotations = map[string]string{"example.com/runbook": "https://example.com/db"}
+	r.Data = map[string]interface{}{"ip": "10.0.0.1"}
+
+	data, err := yaml.Marshal(r)
+	assert.NoError(t, err)
+	fromYAML := &Resource{}
+	assert.NoError(t, yaml.Unmarshal(data, fromYAML))
+	assert.Equal(t, r, fromYAML)
+
+	data, err = json.Marshal(r)
+	assert.NoError(t, err)
+	fromJSON := &Resource{}
+	assert.NoError(t, json.Unmarshal(data, fromJSON))
+	assert.Equal(t, r, fromJSON)
+}
+
+// Test_Labels_Omitted tests that empty labels and annotations are not
+// marshaled.
+func Test_Labels_Omitted(t *testing.T) {
+	t.Parallel()
+	r, err := New("host", "db-01", "", "")
+	assert.NoError(t, err)
+	data, err := yaml.Marshal(r)
+	assert.NoError(t, err)
+	var m map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(data, &m))
+	assert.NotContains(t, m, "labels")
+	assert.NotContains(t, m, "annotations")
+}
+
+// Test_UnmarshalYAML_Labels tests the UnmarshalYAML function with labels of
+// various types.
+func Test_UnmarshalYAML_Labels(t *testing.T) {
+	t.Parallel()
+	r := &Resource{}
+	err := yaml.Unmarshal([]byte(`
+name: db-01
+labels:
+  env: prod
+  replicas: 3
+  primary: true
+  empty:
+host: {}
+`), r)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "replicas": "3", "primary": "true", "empty": ""}, r.Labels)
+	assert.Nil(t, r.Annotations)
+	assert.Equal(t, "host", r.Kind)
+
+	for _, doc := range []string{
+		"name: a\nlabels: prod\nhost: {}\n",
+		"name: a\nlabels: [prod]\nhost: {}\n",
+		"name: a\nannotations:\n  nested: {a: b}\nhost: {}\n",
+	} {
+		err := yaml.Unmarshal([]byte(doc), &Resource{})
+		assert.ErrorIs(t, err, ErrorInvalidMetadata, doc)
+	}
+}
+
+// Test_UnmarshalJSON_Labels tests the UnmarshalJSON function with invalid
+// labels.
+func Test_UnmarshalJSON_Labels(t *testing.T) {
+	t.Parallel()
+	r := &Resource{}
+	err := r.UnmarshalJSON([]byte(`{"name": "a", "labels": {"n": 1.5}, "host": {}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"n": "1.5"}, r.Labels)
+	err = r.UnmarshalJSON([]byte(`{"name": "a", "labels": 1, "host": {}}`))
+	assert.ErrorIs(t, err, ErrorInvalidMetadata)
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Registry holds the schemas of the resource kinds.
+type Registry struct {
+	// Schemas are the schemas organized by kind.
+	Schemas map[string]*Schema
+}
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		Schemas: make(map[string]*Schema),
+	}
+}
+
+// LoadFile loads a schema from a file.
+func LoadFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// LoadDir loads a registry from a directory holding one <kind>.yaml schema
+// file per kind. A missing directory yields an empty registry.
+func LoadDir(dir string) (*Registry, error) {
+	reg := NewRegistry()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return reg, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if e := strings.ToLower(ext); e != ".yaml" && e != ".yml" {
+			continue
+		}
+		s, err := LoadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		reg.Add(strings.TrimSuffix(entry.Name(), ext), s)
+	}
+	return reg, nil
+}
+
+// Add adds the schema of a kind to the registry.
+func (reg *Registry) Add(kind string, s *Schema) {
+	reg.Schemas[kind] = s
+}
+
+// Validate validates the data of a resource of the given kind. The path is
+// prefixed to the paths of the returned violations. Kinds without a schema
+// accept any data.
+func (reg *Registry) Validate(kind, path string, data interface{}) []*Violation {
+	s, ok := reg.Schemas[kind]
+	if !ok {
+		return nil
+	}
+	return s.Validate(path, data)
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_NewRegistry tests the NewRegistry function.
+func Test_NewRegistry(t *testing.T) {
+	t.Parallel()
+	reg := NewRegistry()
+	assert.NotNil(t, reg)
+	assert.Len(t, reg.Schemas, 0)
+}
+
+// Test_LoadDir tests the LoadDir function.
+func Test_LoadDir(t *testing.T) {
+	t.Parallel()
+	reg, err := LoadDir("testdata/schemas")
+	assert.NoError(t, err)
+	assert.Len(t, reg.Schemas, 2)
+	assert.NotNil(t, reg.Schemas["host"])
+	assert.NotNil(t, reg.Schemas["service"])
+}
+
+// Test_LoadDir_Missing tests the LoadDir function with a missing directory.
+func Test_LoadDir_Missing(t *testing.T) {
+	t.Parallel()
+	reg, err := LoadDir("testdata/nonexistent")
+	assert.NoError(t, err)
+	assert.Len(t, reg.Schemas, 0)
+}
+
+// Test_LoadDir_Invalid tests the LoadDir function with an invalid schema.
+func Test_LoadDir_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := LoadDir("testdata/invalid")
+	assert.ErrorContains(t, err, "kind.yaml")
+}
+
+// Test_Registry_Validate tests the Validate function.
+func Test_Registry_Validate(t *testing.T) {
+	t.Parallel()
+	reg, err := LoadDir("testdata/schemas")
+	assert.NoError(t, err)
+	assert.Empty(t, reg.Validate("host", "", map[string]interface{}{
+		"ip": "10.0.0.1",
+		"os": "linux",
+	}))
+	assert.Len(t, reg.Validate("host", "", map[string]interface{}{
+		"ip":    "ten",
+		"os":    "plan9",
+		"extra": true,
+	}), 3)
+	assert.Empty(t, reg.Validate("unknown", "", 1))
+}
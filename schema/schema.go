@@ -0,0 +1,319 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a JSON Schema. Only a subset of the specification is supported:
+//
+//   - type (a single type or a list of types)
+//   - enum
+//   - properties, required and additionalProperties
+//   - items, minItems and maxItems
+//   - pattern, minLength and maxLength
+//   - minimum and maximum
+//
+// The boolean schemas true and false are also supported.
+type Schema struct {
+	// Description is the description of the schema.
+	Description string `yaml:"description,omitempty"`
+	// Type is the list of allowed types.
+	Type Types `yaml:"type,omitempty"`
+	// Enum is the list of allowed values.
+	Enum []interface{} `yaml:"enum,omitempty"`
+	// Properties are the schemas of the object properties.
+	Properties map[string]*Schema `yaml:"properties,omitempty"`
+	// Required is the list of required object properties.
+	Required []string `yaml:"required,omitempty"`
+	// AdditionalProperties is the schema of properties not listed in
+	// Properties.
+	AdditionalProperties *Schema `yaml:"additionalProperties,omitempty"`
+	// Items is the schema of the array items.
+	Items *Schema `yaml:"items,omitempty"`
+	// MinItems is the minimum number of array items.
+	MinItems *int `yaml:"minItems,omitempty"`
+	// MaxItems is the maximum number of array items.
+	MaxItems *int `yaml:"maxItems,omitempty"`
+	// Pattern is a regular expression strings must match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// MinLength is the minimum length of strings.
+	MinLength *int `yaml:"minLength,omitempty"`
+	// MaxLength is the maximum length of strings.
+	MaxLength *int `yaml:"maxLength,omitempty"`
+	// Minimum is the minimum value of numbers.
+	Minimum *float64 `yaml:"minimum,omitempty"`
+	// Maximum is the maximum value of numbers.
+	Maximum *float64 `yaml:"maximum,omitempty"`
+	// always is set for the boolean schemas true and false.
+	always *bool
+	// pattern is the compiled Pattern.
+	pattern *regexp.Regexp
+}
+
+// Types is a list of JSON Schema types.
+type Types []string
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. It accepts a
+// single type as well as a list of types.
+func (t *Types) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = Types{value.Value}
+		return nil
+	}
+	var types []string
+	if err := value.Decode(&types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *Schema) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!bool" {
+		var b bool
+		if err := value.Decode(&b); err != nil {
+			return err
+		}
+		s.always = &b
+		return nil
+	}
+	type plain Schema
+	if err := value.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	for _, typ := range s.Type {
+		switch typ {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return fmt.Errorf("line %d: unknown schema type: %s", value.Line, typ)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid pattern: %w", value.Line, err)
+		}
+		s.pattern = re
+	}
+	return nil
+}
+
+// Violation is a value that does not conform to a schema.
+type Violation struct {
+	// Path is the dotted path to the offending value.
+	Path string
+	// Message describes the violation.
+	Message string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Validate validates a value against the schema. The path is prefixed to the
+// paths of the returned violations.
+func (s *Schema) Validate(path string, value interface{}) []*Violation {
+	if s == nil {
+		return nil
+	}
+	if s.always != nil {
+		if *s.always {
+			return nil
+		}
+		return []*Violation{{Path: path, Message: "value is not allowed"}}
+	}
+	if len(s.Type) > 0 && !s.hasType(value) {
+		return []*Violation{{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), typeOf(value))}}
+	}
+	violations := []*Violation{}
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("value must be one of %v", s.Enum)})
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(path, v)...)
+	case []interface{}:
+		violations = append(violations, s.validateArray(path, v)...)
+	case string:
+		violations = append(violations, s.validateString(path, v)...)
+	default:
+		if n, ok := toFloat(value); ok {
+			violations = append(violations, s.validateNumber(path, n)...)
+		}
+	}
+	return violations
+}
+
+// validateObject validates an object.
+func (s *Schema) validateObject(path string, obj map[string]interface{}) []*Violation {
+	violations := []*Violation{}
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("missing required property %q", name)})
+		}
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if prop, ok := s.Properties[k]; ok {
+			violations = append(violations, prop.Validate(join(path, k), obj[k])...)
+		} else {
+			violations = append(violations, s.AdditionalProperties.Validate(join(path, k), obj[k])...)
+		}
+	}
+	return violations
+}
+
+// validateArray validates an array.
+func (s *Schema) validateArray(path string, arr []interface{}) []*Violation {
+	violations := []*Violation{}
+	if s.MinItems != nil && len(arr) < *s.MinItems {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.MinItems)})
+	}
+	if s.MaxItems != nil && len(arr) > *s.MaxItems {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.MaxItems)})
+	}
+	for i, item := range arr {
+		violations = append(violations, s.Items.Validate(join(path, strconv.Itoa(i)), item)...)
+	}
+	return violations
+}
+
+// validateString validates a string.
+func (s *Schema) validateString(path string, str string) []*Violation {
+	violations := []*Violation{}
+	n := len([]rune(str))
+	if s.MinLength != nil && n < *s.MinLength {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must be at least %d characters", *s.MinLength)})
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must be at most %d characters", *s.MaxLength)})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must match pattern %q", s.Pattern)})
+	}
+	return violations
+}
+
+// validateNumber validates a number.
+func (s *Schema) validateNumber(path string, n float64) []*Violation {
+	violations := []*Violation{}
+	if s.Minimum != nil && n < *s.Minimum {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must be at least %v", *s.Minimum)})
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		violations = append(violations, &Violation{Path: path, Message: fmt.Sprintf("must be at most %v", *s.Maximum)})
+	}
+	return violations
+}
+
+// hasType returns true if the value has one of the schema types.
+func (s *Schema) hasType(value interface{}) bool {
+	actual := typeOf(value)
+	for _, typ := range s.Type {
+		if typ == actual {
+			return true
+		}
+		if typ == "number" && actual == "integer" {
+			return true
+		}
+		if typ == "integer" && actual == "number" {
+			if n, _ := toFloat(value); n == math.Trunc(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inEnum returns true if the value is one of the enum values.
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, e := range s.Enum {
+		if a, ok := toFloat(value); ok {
+			if b, ok := toFloat(e); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(value, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of a value.
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// toFloat converts a numeric value to a float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// join joins a dotted path and a key.
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 Scott M. Long
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// mustParse parses a schema and fails the test on error.
+func mustParse(t *testing.T, src string) *Schema {
+	t.Helper()
+	s := &Schema{}
+	if err := yaml.Unmarshal([]byte(src), s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// messages returns the error strings of the violations.
+func messages(violations []*Violation) []string {
+	msgs := []string{}
+	for _, v := range violations {
+		msgs = append(msgs, v.Error())
+	}
+	return msgs
+}
+
+// Test_Schema_Unmarshal_Invalid tests unmarshaling invalid schemas.
+func Test_Schema_Unmarshal_Invalid(t *testing.T) {
+	t.Parallel()
+	for _, src := range []string{
+		"type: strange",
+		"pattern: '['",
+		"type: {}",
+		"- not a schema",
+	} {
+		s := &Schema{}
+		assert.Error(t, yaml.Unmarshal([]byte(src), s), src)
+	}
+}
+
+// Test_Schema_Validate_Type tests type validation.
+func Test_Schema_Validate_Type(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		schema string
+		value  interface{}
+		valid  bool
+	}{
+		{"type: string", "x", true},
+		{"type: string", 1, false},
+		{"type: integer", 1, true},
+		{"type: integer", 1.0, true},
+		{"type: integer", 1.5, false},
+		{"type: number", 1, true},
+		{"type: number", 1.5, true},
+		{"type: boolean", true, true},
+		{"type: null", nil, true},
+		{"type: object", map[string]interface{}{}, true},
+		{"type: array", []interface{}{}, true},
+		{"type: array", map[string]interface{}{}, false},
+		{"type: [string, integer]", 1, true},
+		{"type: [string, integer]", true, false},
+		{"true", 1, true},
+		{"false", 1, false},
+	}
+	for _, c := range cases {
+		s := mustParse(t, c.schema)
+		assert.Equal(t, c.valid, len(s.Validate("", c.value)) == 0, "%s: %v", c.schema, c.value)
+	}
+}
+
+// Test_Schema_Validate_Object tests object validation.
+func Test_Schema_Validate_Object(t *testing.T) {
+	t.Parallel()
+	s := mustParse(t, `
+type: object
+required: [ip, os]
+properties:
+  ip:
+    type: string
+additionalProperties:
+  type: integer
+`)
+	assert.Equal(t, []string{
+		`data: missing required property "os"`,
+		"data.extra: expected integer, got string",
+		"data.ip: expected string, got integer",
+	}, messages(s.Validate("data", map[string]interface{}{
+		"ip":    1,
+		"extra": "x",
+	})))
+	assert.Empty(t, s.Validate("data", map[string]interface{}{
+		"ip":    "10.0.0.1",
+		"os":    1,
+		"extra": 2,
+	}))
+}
+
+// Test_Schema_Validate_Array tests array validation.
+func Test_Schema_Validate_Array(t *testing.T) {
+	t.Parallel()
+	s := mustParse(t, `
+type: array
+minItems: 1
+maxItems: 2
+items:
+  type: string
+`)
+	assert.Equal(t, []string{"must have at least 1 items"}, messages(s.Validate("", []interface{}{})))
+	assert.Equal(t, []string{
+		"must have at most 2 items",
+		"2: expected string, got integer",
+	}, messages(s.Validate("", []interface{}{"a", "b", 3})))
+	assert.Empty(t, s.Validate("", []interface{}{"a"}))
+}
+
+// Test_Schema_Validate_String tests string validation.
+func Test_Schema_Validate_String(t *testing.T) {
+	t.Parallel()
+	s := mustParse(t, `
+minLength: 2
+maxLength: 4
+pattern: ^[a-z]+$
+`)
+	assert.Len(t, s.Validate("", "a"), 1)
+	assert.Len(t, s.Validate("", "abcde"), 1)
+	assert.Len(t, s.Validate("", "AB"), 1)
+	assert.Empty(t, s.Validate("", "abc"))
+}
+
+// Test_Schema_Validate_Number tests number validation.
+func Test_Schema_Validate_Number(t *testing.T) {
+	t.Parallel()
+	s := mustParse(t, `
+minimum: 1
+maximum: 10.5
+`)
+	assert.Len(t, s.Validate("", 0), 1)
+	assert.Len(t, s.Validate("", 11), 1)
+	assert.Empty(t, s.Validate("", 10.5))
+}
+
+// Test_Schema_Validate_Enum tests enum validation.
+func Test_Schema_Validate_Enum(t *testing.T) {
+	t.Parallel()
+	s := mustParse(t, `enum: [a, 1, true]`)
+	assert.Empty(t, s.Validate("", "a"))
+	assert.Empty(t, s.Validate("", 1.0))
+	assert.Empty(t, s.Validate("", true))
+	assert.Len(t, s.Validate("", "b"), 1)
+}
+
+// Test_Schema_Validate_Nil tests validating against a nil schema.
+func Test_Schema_Validate_Nil(t *testing.T) {
+	t.Parallel()
+	var s *Schema
+	assert.Empty(t, s.Validate("", "anything"))
+}